@@ -0,0 +1,272 @@
+package cnab
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Values of the `count` tag.
+const (
+	CountRecords = "records" // records in the lot or file, including its header and trailer
+	CountLots    = "lots"    // lots closed in the file
+)
+
+// tally accumulates the records of a lot or file for trailer aggregates.
+type tally struct {
+	records int
+	lots    int
+	sums    map[sumKey]Decimal
+}
+
+// sumKey identifies a numeric field of a record type.
+type sumKey struct {
+	typ   string
+	field string
+}
+
+func newTally() *tally {
+	return &tally{sums: make(map[sumKey]Decimal)}
+}
+
+// summand is the value of a numeric field as written in a record.
+type summand struct {
+	field string
+	value Decimal
+}
+
+// summands parses the numeric fields of c as written in line. Blank fields
+// are zero; fields that fail to parse are left out and reported, while the
+// others are still returned.
+func (c *codec) summands(line chars) ([]summand, error) {
+	var s []summand
+	var errs ErrorList
+	for _, f := range c.numbers {
+		d, ok, err := f.number(line)
+		if err != nil {
+			errs.add(err)
+			continue
+		}
+		if !ok {
+			d = Decimal{}
+		}
+		s = append(s, summand{f.name, d})
+	}
+	if len(errs) == 1 {
+		return s, errs[0]
+	}
+	return s, errs.err()
+}
+
+// add counts a record of type typ, summing the values s of its numeric
+// fields.
+func (t *tally) add(typ reflect.Type, s []summand) {
+	t.records++
+	for _, v := range s {
+		key := sumKey{typ.Name(), v.field}
+		t.sums[key] = t.sums[key].Add(v.value)
+	}
+}
+
+// total returns the value of the aggregate field f of the trailer being
+// written, which counts itself as a record.
+func (t *tally) total(f *codecField) Decimal {
+	switch f.tag.count {
+	case CountRecords:
+		return NewDecimal(int64(t.records+1), 0)
+	case CountLots:
+		return NewDecimal(int64(t.lots), 0)
+	}
+
+	sum := NewDecimal(0, 0)
+	for key, d := range t.sums {
+		if key.matches(f.tag.sum) {
+			sum = sum.Add(d)
+		}
+	}
+	return sum
+}
+
+// matches reports whether k is the field named by a sum tag.
+func (k sumKey) matches(sum string) bool {
+	typ, field, typed := strings.Cut(sum, ".")
+	return k.field == sum || typed && k.typ == typ && k.field == field
+}
+
+// checkSums reports the sum fields of c that match no field tallied in t,
+// which catch misspelled names before a wrong total is written.
+func (t *tally) checkSums(c *codec) error {
+	var errs ErrorList
+	for _, f := range c.totals {
+		if f.tag.sum == "" || t.has(f.tag.sum) {
+			continue
+		}
+		errs.add(f.fieldError(fmt.Errorf("%w: %s", ErrUnknownSum, f.tag.sum), ""))
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs.err()
+}
+
+// has reports whether a record tallied in t has the field named by sum.
+func (t *tally) has(sum string) bool {
+	for key := range t.sums {
+		if key.matches(sum) {
+			return true
+		}
+	}
+	return false
+}
+
+// fill sets the aggregate fields of the trailer rv from t.
+func (t *tally) fill(c *codec, rv reflect.Value) error {
+	for _, f := range c.totals {
+		if err := setNumber(f.target(rv), t.total(f), &f.tag); err != nil {
+			return f.fieldError(err, "")
+		}
+	}
+	return nil
+}
+
+// verifier checks the aggregates of trailers against the records read,
+// tallied the way Writer fills them.
+type verifier struct {
+	file *tally
+	lot  *tally // nil outside lots
+}
+
+func newVerifier() *verifier {
+	return &verifier{file: newTally()}
+}
+
+// record verifies and tallies line, a record of codec c and type t; c is
+// nil for lines of unknown type. openLot and closeLot report whether the
+// record is a lot header or a lot trailer.
+func (v *verifier) record(c *codec, t reflect.Type, line chars, openLot, closeLot bool) error {
+	if openLot {
+		v.lot = newTally()
+	}
+	if c == nil {
+		v.file.records++
+		if v.lot != nil {
+			v.lot.records++
+		}
+		return nil
+	}
+
+	scope := v.file
+	if v.lot != nil {
+		scope = v.lot
+	}
+	var errs ErrorList
+	for _, f := range c.totals {
+		if err := scope.verify(f, line); err != nil {
+			errs.add(err)
+		}
+	}
+
+	// Fields that fail to parse are reported by the decoder
+	s, _ := c.summands(line)
+	v.file.add(t, s)
+	if v.lot != nil {
+		v.lot.add(t, s)
+	}
+	if closeLot && v.lot != nil {
+		v.lot = nil
+		v.file.lots++
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs.err()
+}
+
+// verify compares the aggregate field f of the trailer line with the
+// value t computes for it.
+func (t *tally) verify(f *codecField, line chars) error {
+	actual, ok, err := f.number(line)
+	if err != nil || f.end > line.len() {
+		return nil // reported by the decoder
+	}
+	if !ok {
+		actual = NewDecimal(0, f.tag.decimal)
+	}
+
+	expected := t.total(f).Rescale(f.tag.decimal, f.tag.rounding)
+	if expected.Cmp(actual) == 0 {
+		return nil
+	}
+	return f.fieldError(&IntegrityError{Expected: expected, Actual: actual}, line.slice(f.start, f.end))
+}
+
+// number parses the numeric field f as written in line, honoring its
+// implied decimals. Blank fields report false.
+func (f *codecField) number(line chars) (Decimal, bool, error) {
+	if f.end > line.len() {
+		return Decimal{}, false, nil
+	}
+	raw := line.slice(f.start, f.end)
+	if f.blank != "" && raw == f.blank {
+		return Decimal{}, false, nil
+	}
+	s := trimCode(raw, &f.tag)
+	if s == "" {
+		return Decimal{}, false, nil
+	}
+	d, err := ParseImplicitDecimal(s, f.tag.decimal)
+	if err != nil {
+		return Decimal{}, false, f.fieldError(err, raw)
+	}
+	return d, true, nil
+}
+
+// numeric reports whether t, or the type it points to, holds a number.
+func numeric(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == decimalType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setNumber stores d in the numeric value v. Integers hold the value
+// scaled by the field's implied decimals, e.g. cents with decimal:2.
+func setNumber(v reflect.Value, d Decimal, tag *fieldTag) error {
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	if v.Type() == decimalType {
+		v.Set(reflect.ValueOf(d))
+		return nil
+	}
+
+	n := d.Rescale(tag.decimal, tag.rounding).Unscaled()
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !n.IsInt64() || v.OverflowInt(n.Int64()) {
+			return fmt.Errorf("%w: %s overflows %s", ErrFieldSizeMismatch, d, v.Type())
+		}
+		v.SetInt(n.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+			return fmt.Errorf("%w: %s overflows %s", ErrFieldSizeMismatch, d, v.Type())
+		}
+		v.SetUint(n.Uint64())
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(d.Float64())
+	default:
+		return ErrUnsupportedType
+	}
+	return nil
+}
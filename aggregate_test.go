@@ -0,0 +1,189 @@
+package cnab
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type aggHeader struct {
+	Type string `cnab:"literal:0"`
+	Name string `cnab:"size:9"`
+}
+
+type aggDetail struct {
+	Type   string  `cnab:"literal:1"`
+	Amount Decimal `cnab:"size:7;fill:0;align:right;decimal:2"`
+	Fee    int64   `cnab:"size:2;fill:0;align:right"`
+}
+
+type aggTrailer struct {
+	Type    string  `cnab:"literal:9"`
+	Records int     `cnab:"size:3;fill:0;align:right;count:records"`
+	Total   Decimal `cnab:"size:6;fill:0;align:right;decimal:2;sum:aggDetail.Amount"`
+	Fees    int64   `cnab:"size:1;fill:0;align:right;sum:Fee"`
+}
+
+func TestWriterAggregates(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetLineEnding(LF)
+
+	records := []interface{}{
+		aggHeader{Name: "BANK"},
+		aggDetail{Amount: NewDecimal(1050, 2), Fee: 2},
+		aggDetail{Amount: NewDecimal(25, 1), Fee: 3},
+	}
+	for _, v := range records {
+		if err := w.Write(v); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	trailer := &aggTrailer{}
+	if err := w.Write(trailer); err != nil {
+		t.Fatalf("Write trailer failed: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	want := "0BANK     \n1000105002\n1000025003\n90040013005\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected file:\nGot:  %q\nWant: %q", buf.String(), want)
+	}
+	if trailer.Records != 4 || trailer.Total.String() != "13.00" {
+		t.Fatalf("trailer not filled in place: %+v", trailer)
+	}
+}
+
+type aggLotTrailer struct {
+	Type    string `cnab:"literal:5"`
+	Records int    `cnab:"size:2;fill:0;align:right;count:records"`
+	Total   int64  `cnab:"size:6;fill:0;align:right;decimal:2;sum:aggDetail.Amount"`
+}
+
+type aggFileTrailer struct {
+	Type    string `cnab:"literal:9"`
+	Lots    int    `cnab:"size:2;fill:0;align:right;count:lots"`
+	Records int    `cnab:"size:2;fill:0;align:right;count:records"`
+}
+
+func TestWriterLotAggregates(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetLineEnding(LF)
+
+	lot := func(amounts ...int64) Lot {
+		l := Lot{Header: aggHeader{Name: "LOT"}, Trailer: aggLotTrailer{}}
+		for _, a := range amounts {
+			l.Details = append(l.Details, aggDetail{Amount: NewDecimal(a, 2)})
+		}
+		return l
+	}
+	f := &File{
+		Header:  aggHeader{Name: "FILE"},
+		Lots:    []Lot{lot(100, 250), lot(5)},
+		Trailer: aggFileTrailer{},
+	}
+	if err := w.WriteFile(f); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	want := "0FILE     \n" +
+		"0LOT      \n1000010000\n1000025000\n504000350\n" +
+		"0LOT      \n1000000500\n503000005\n" +
+		"90209\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected file:\nGot:  %q\nWant: %q", buf.String(), want)
+	}
+
+	if err := w.EndLot(aggLotTrailer{}); !errors.Is(err, ErrFileStructure) {
+		t.Fatalf("expected ErrFileStructure, got %v", err)
+	}
+}
+
+// aggCode is numeric in Go but not in the file.
+type aggCode int
+
+func (c aggCode) MarshalCNAB() ([]byte, error) {
+	return []byte("AB"), nil
+}
+
+func (c *aggCode) UnmarshalCNAB(data []byte) error {
+	return nil
+}
+
+func TestWriterSkipsNonNumbers(t *testing.T) {
+	type Custom struct {
+		Code   aggCode `cnab:"size:2"`
+		Amount int     `cnab:"size:3;fill:0;align:right"`
+	}
+	type Blank struct {
+		Type   string `cnab:"literal:1"`
+		D      *int   `cnab:"size:3;blank:-"`
+		Amount int    `cnab:"size:3;fill:0;align:right"`
+	}
+	type Trailer struct {
+		Total int `cnab:"size:3;fill:0;align:right;sum:Amount"`
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetLineEnding(LF)
+	for _, v := range []interface{}{Custom{Amount: 5}, Blank{Amount: 7}, Trailer{}} {
+		if err := w.Write(v); err != nil {
+			t.Fatalf("%T: Write failed: %v", v, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	want := "AB005\n1---007\n012\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected file:\nGot:  %q\nWant: %q", buf.String(), want)
+	}
+}
+
+func TestWriterUnknownSum(t *testing.T) {
+	type Trailer struct {
+		Type  string `cnab:"literal:9"`
+		Total int    `cnab:"size:3;fill:0;align:right;sum:Amout"`
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Write(aggDetail{Amount: NewDecimal(100, 2)}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Write(Trailer{}); !errors.Is(err, ErrUnknownSum) {
+		t.Fatalf("expected ErrUnknownSum, got %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	if n := bytes.Count(buf.Bytes(), []byte("\r\n")); n != 1 {
+		t.Fatalf("expected only the detail to be written, got %q", buf.String())
+	}
+}
+
+func TestAggregateInvalidTag(t *testing.T) {
+	type Unknown struct {
+		N int `cnab:"size:2;count:pages"`
+	}
+	type Text struct {
+		S string `cnab:"size:2;sum:Amount"`
+	}
+	type Malformed struct {
+		N int `cnab:"size:2;sum:aggDetail."`
+	}
+
+	for _, v := range []interface{}{Unknown{}, Text{}, Malformed{}} {
+		if _, err := Marshal(v); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%T: expected ErrInvalidTag, got %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cnab
+
+import (
+	"errors"
+	"testing"
+)
+
+type boolRecord struct {
+	Active  bool `cnab:"size:1"`
+	Blocked bool `cnab:"true:1;false:2"`
+	Status  bool `cnab:"size:3;true:A;false:I;fill:0;align:right"`
+}
+
+func TestBoolRoundTrip(t *testing.T) {
+	in := boolRecord{Active: true, Blocked: false, Status: true}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != "S200A" {
+		t.Fatalf("unexpected line: '%s'", data)
+	}
+
+	var out boolRecord
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out != in {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+}
+
+func TestBoolStrictDecode(t *testing.T) {
+	for _, line := range []string{"X100A", " 100A", "S300A", "S100X"} {
+		var out boolRecord
+		err := Unmarshal([]byte(line), &out)
+		if !errors.Is(err, ErrInvalidBool) {
+			t.Errorf("%q: expected ErrInvalidBool, got %v", line, err)
+		}
+	}
+}
+
+func TestBoolRequired(t *testing.T) {
+	type Flags struct {
+		Active bool  `cnab:"size:1;required"`
+		Opt    *bool `cnab:"size:1;required"`
+	}
+
+	no := false
+	data, err := Marshal(Flags{Opt: &no})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != "NN" {
+		t.Fatalf("unexpected line: '%s'", data)
+	}
+
+	if _, err := Marshal(Flags{}); !errors.Is(err, ErrRequired) {
+		t.Fatalf("expected ErrRequired for a nil *bool, got %v", err)
+	}
+	var out Flags
+	if err := Unmarshal([]byte(" N"), &out); !errors.Is(err, ErrRequired) {
+		t.Fatalf("expected ErrRequired for a blank code, got %v", err)
+	}
+}
+
+func TestBoolInvalidTag(t *testing.T) {
+	type Same struct {
+		Flag bool `cnab:"true:S;false:S"`
+	}
+	if _, err := Marshal(Same{}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package cnab
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset converts CNAB files between their byte encoding and UTF-8 Go
+// strings. Fields are always positioned by character.
+type Charset struct {
+	name   string
+	high   *[128]rune // runes of bytes 0x80-0xFF; nil for UTF-8 and ASCII
+	utf8   bool
+	encode map[rune]byte // inverse of high
+}
+
+// Supported charsets.
+var (
+	UTF8        = &Charset{name: "UTF-8", utf8: true}
+	ASCII       = &Charset{name: "ASCII"}
+	ISO88591    = newSingleByte("ISO-8859-1", latin1High())
+	Windows1252 = newSingleByte("Windows-1252", windows1252High())
+)
+
+func newSingleByte(name string, high *[128]rune) *Charset {
+	cs := &Charset{name: name, high: high, encode: make(map[rune]byte, len(high))}
+	for i, r := range high {
+		cs.encode[r] = byte(0x80 + i)
+	}
+	return cs
+}
+
+// ParseCharset returns the charset named s, e.g. "ISO-8859-1" or "latin1",
+// ignoring case.
+func ParseCharset(s string) (*Charset, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "UTF-8", "UTF8":
+		return UTF8, nil
+	case "ASCII", "US-ASCII":
+		return ASCII, nil
+	case "ISO-8859-1", "ISO8859-1", "LATIN1":
+		return ISO88591, nil
+	case "WINDOWS-1252", "CP1252":
+		return Windows1252, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrCharset, s)
+}
+
+func (cs *Charset) String() string {
+	return cs.name
+}
+
+// Decode converts data to a UTF-8 string, failing on bytes that are not
+// valid in cs.
+func (cs *Charset) Decode(data []byte) (string, error) {
+	if cs.utf8 {
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("%w: invalid %s", ErrCharset, cs)
+		}
+		return string(data), nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(data))
+	for i, c := range data {
+		switch {
+		case c < utf8.RuneSelf:
+			b.WriteByte(c)
+		case cs.high == nil:
+			return "", fmt.Errorf("%w: byte 0x%02X at %d not in %s", ErrCharset, c, i+1, cs)
+		default:
+			b.WriteRune(cs.high[c-0x80])
+		}
+	}
+	return b.String(), nil
+}
+
+// Encode converts s to cs, failing on characters cs cannot represent.
+func (cs *Charset) Encode(s string) ([]byte, error) {
+	if cs.utf8 {
+		return []byte(s), nil
+	}
+
+	data := make([]byte, 0, len(s))
+	for i, r := range []rune(s) {
+		switch b, ok := cs.encode[r]; {
+		case r < utf8.RuneSelf:
+			data = append(data, byte(r))
+		case ok:
+			data = append(data, b)
+		default:
+			return nil, fmt.Errorf("%w: %q at %d not in %s", ErrCharset, r, i+1, cs)
+		}
+	}
+	return data, nil
+}
+
+// latin1High maps bytes 0x80-0xFF to the code points of the same value.
+func latin1High() *[128]rune {
+	var high [128]rune
+	for i := range high {
+		high[i] = rune(0x80 + i)
+	}
+	return &high
+}
+
+// windows1252High is ISO-8859-1 with printable characters in 0x80-0x9F.
+// The five unassigned bytes keep their ISO-8859-1 control characters.
+func windows1252High() *[128]rune {
+	high := latin1High()
+	copy(high[:32], []rune{
+		'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+		0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+	})
+	return high
+}
+
+// chars indexes a record by character rather than by byte.
+type chars struct {
+	s     string
+	runes []rune // nil when s is ASCII
+}
+
+func newChars(s string) chars {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return chars{s: s, runes: []rune(s)}
+		}
+	}
+	return chars{s: s}
+}
+
+// len returns the number of characters.
+func (c chars) len() int {
+	if c.runes != nil {
+		return len(c.runes)
+	}
+	return len(c.s)
+}
+
+// slice returns the characters at the 1-based positions start to end,
+// inclusive.
+func (c chars) slice(start, end int) string {
+	if c.runes != nil {
+		return string(c.runes[start-1 : end])
+	}
+	return c.s[start-1 : end]
+}
+
+// runeCount returns the number of characters of s.
+func runeCount(s string) int {
+	return utf8.RuneCountInString(s)
+}
@@ -0,0 +1,180 @@
+package cnab
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCharsetRoundTrip(t *testing.T) {
+	tests := []struct {
+		cs   *Charset
+		text string
+		data []byte
+	}{
+		{UTF8, "Ação", []byte("Ação")},
+		{ASCII, "ABC", []byte("ABC")},
+		{ISO88591, "Ação", []byte{'A', 0xE7, 0xE3, 'o'}},
+		{Windows1252, "€ “ok”", []byte{0x80, ' ', 0x93, 'o', 'k', 0x94}},
+	}
+
+	for _, tt := range tests {
+		data, err := tt.cs.Encode(tt.text)
+		if err != nil {
+			t.Fatalf("%s: Encode: %v", tt.cs, err)
+		}
+		if !bytes.Equal(data, tt.data) {
+			t.Errorf("%s: Encode(%q) = % X, want % X", tt.cs, tt.text, data, tt.data)
+		}
+		text, err := tt.cs.Decode(data)
+		if err != nil {
+			t.Fatalf("%s: Decode: %v", tt.cs, err)
+		}
+		if text != tt.text {
+			t.Errorf("%s: Decode = %q, want %q", tt.cs, text, tt.text)
+		}
+	}
+}
+
+func TestCharsetErrors(t *testing.T) {
+	if _, err := ISO88591.Encode("ok 😀"); !errors.Is(err, ErrCharset) {
+		t.Errorf("ISO-8859-1 Encode: expected ErrCharset, got %v", err)
+	}
+	if _, err := ISO88591.Encode("€"); !errors.Is(err, ErrCharset) {
+		t.Errorf("ISO-8859-1 Encode(€): expected ErrCharset, got %v", err)
+	}
+	if _, err := ASCII.Decode([]byte{'A', 0xE7}); !errors.Is(err, ErrCharset) {
+		t.Errorf("ASCII Decode: expected ErrCharset, got %v", err)
+	}
+	if _, err := UTF8.Decode([]byte{'A', 0xE7}); !errors.Is(err, ErrCharset) {
+		t.Errorf("UTF-8 Decode: expected ErrCharset, got %v", err)
+	}
+	if _, err := ParseCharset("EBCDIC"); !errors.Is(err, ErrCharset) {
+		t.Errorf("ParseCharset: expected ErrCharset, got %v", err)
+	}
+	if cs, err := ParseCharset("latin1"); err != nil || cs != ISO88591 {
+		t.Errorf("ParseCharset(latin1) = %v, %v", cs, err)
+	}
+}
+
+type charsetRecord struct {
+	Name string `cnab:"size:6"`
+	City string `cnab:"size:5"`
+	Code string `cnab:"size:2"`
+}
+
+func TestEncodeDecodeByCharacter(t *testing.T) {
+	in := charsetRecord{Name: "João", City: "Sé", Code: "01"}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "João  Sé   01"; string(data) != want {
+		t.Errorf("Marshal = %q, want %q", data, want)
+	}
+
+	var out charsetRecord
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("Unmarshal = %+v, want %+v", out, in)
+	}
+
+	if _, err := Marshal(charsetRecord{Name: "Conceição"}); !errors.Is(err, ErrFieldSizeMismatch) {
+		t.Errorf("expected ErrFieldSizeMismatch, got %v", err)
+	}
+}
+
+func TestEncoderDecoderCharset(t *testing.T) {
+	in := charsetRecord{Name: "João", City: "Sé", Code: "01"}
+
+	enc := NewEncoder()
+	enc.SetCharset(ISO88591)
+	data, err := enc.Encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 13 {
+		t.Errorf("encoded %d bytes, want 13", len(data))
+	}
+
+	dec := NewDecoder()
+	dec.SetCharset(ISO88591)
+	var out charsetRecord
+	if err := dec.Decode(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Errorf("Decode = %+v, want %+v", out, in)
+	}
+
+	enc.SetCharset(ASCII)
+	if _, err := enc.Encode(in); !errors.Is(err, ErrCharset) {
+		t.Errorf("expected ErrCharset, got %v", err)
+	}
+}
+
+func TestReaderWriterCharset(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetCharset(Windows1252)
+	w.SetRecordLength(13)
+	if err := w.Write(charsetRecord{Name: "Gonçal", City: "Sé", Code: "01"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteLine([]byte("Conceição  02")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "Gon\xe7al") {
+		t.Errorf("written %q, want Windows-1252", buf.String())
+	}
+
+	r := NewReader(&buf)
+	r.SetCharset(Windows1252)
+	r.SetDiscriminator(Position(12, 13))
+	r.Register("01", charsetRecord{})
+	r.Register("02", charsetRecord{})
+
+	want := []charsetRecord{
+		{Name: "Gonçal", City: "Sé", Code: "01"},
+		{Name: "Concei", City: "ção", Code: "02"},
+	}
+	for i, w := range want {
+		rec, err := r.Read()
+		if err != nil {
+			t.Fatalf("record %d: %v", i+1, err)
+		}
+		if got := *rec.Value.(*charsetRecord); got != w {
+			t.Errorf("record %d = %+v, want %+v", i+1, got, w)
+		}
+	}
+}
+
+func TestReaderCharsetCollect(t *testing.T) {
+	input := "João  Sé   01\nJo\xe7o  Sé   01\nAna   Rio  01\n"
+
+	r := NewReader(strings.NewReader(input))
+	r.SetCharset(UTF8)
+	r.SetDiscriminator(Position(12, 13))
+	r.Register("01", charsetRecord{})
+	r.CollectErrors()
+
+	records, err := r.ReadAll()
+	if len(records) != 2 || records[1].Line != 3 {
+		t.Fatalf("expected records from lines 1 and 3, got %d", len(records))
+	}
+
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 1 || !errors.Is(list[0], ErrCharset) {
+		t.Fatalf("expected one ErrCharset, got %v", err)
+	}
+	if !strings.HasPrefix(list[0].Error(), "line 2:") {
+		t.Errorf("error %q does not name line 2", list[0])
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/HigorGrigorio/cnab/dynamic"
+)
+
+// record is a struct type to generate.
+type record struct {
+	Name   string // Go type name
+	Doc    string
+	Fields dynamic.Fields
+}
+
+// generate renders the tagged struct types of records as a gofmt'd Go file.
+func generate(pkg string, records []record) ([]byte, error) {
+	var body bytes.Buffer
+	imports := make(map[string]bool)
+
+	for _, rec := range records {
+		if rec.Doc != "" {
+			fmt.Fprintf(&body, "// %s\n", rec.Doc)
+		}
+		fmt.Fprintf(&body, "type %s struct {\n", rec.Name)
+
+		names := make(map[string]bool)
+		for _, f := range rec.Fields {
+			name := goName(f)
+			if names[name] {
+				return nil, fmt.Errorf("record %s: duplicate field %s", rec.Name, name)
+			}
+			names[name] = true
+
+			typ, pkgPath := goType(f.Type)
+			if typ == "" {
+				return nil, fmt.Errorf("record %s: field %s: unknown type %q", rec.Name, f.Name, f.Type)
+			}
+			if f.Optional {
+				typ = "*" + typ
+			}
+			if pkgPath != "" {
+				imports[pkgPath] = true
+			}
+
+			tag, err := f.Tag()
+			if err != nil {
+				return nil, fmt.Errorf("record %s: %w", rec.Name, err)
+			}
+			if name != f.Name {
+				fmt.Fprintf(&body, "\t// %s\n", f.Name)
+			}
+			fmt.Fprintf(&body, "\t%s %s `cnab:%q`\n", name, typ, tag)
+		}
+		body.WriteString("}\n\n")
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by cnabgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	if len(imports) > 0 {
+		out.WriteString("import (\n")
+		if imports["time"] {
+			out.WriteString("\t\"time\"\n\n")
+		}
+		if imports["github.com/HigorGrigorio/cnab"] {
+			out.WriteString("\t\"github.com/HigorGrigorio/cnab\"\n")
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(body.Bytes())
+
+	return format.Source(out.Bytes())
+}
+
+// goType returns the Go type generated for a dynamic type and the import
+// path it needs. The types match what dynamic.FromStruct maps back.
+func goType(t string) (typ, pkgPath string) {
+	switch t {
+	case "", "string":
+		return "string", ""
+	case "int":
+		return "int64", ""
+	case "float":
+		return "float64", ""
+	case "decimal":
+		return "cnab.Decimal", "github.com/HigorGrigorio/cnab"
+	case "date":
+		return "time.Time", "time"
+	case "bool":
+		return "bool", ""
+	}
+	return "", ""
+}
+
+// goName returns a Go identifier for f. Valid identifiers are kept as is,
+// so names round-trip; fields must be exported unless they are literals.
+func goName(f dynamic.Field) string {
+	name := identifier(f.Name)
+	if f.Literal == "" {
+		name = exported(name)
+	}
+	return name
+}
+
+// identifier turns a free-form name such as "Nome do Banco" into NomeDoBanco.
+func identifier(s string) string {
+	valid := s != ""
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			valid = false
+		}
+	}
+	if valid {
+		return s
+	}
+
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		b.WriteString(exported(word))
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "F" + name
+	}
+	return name
+}
+
+// exported upper-cases the first letter of s.
+func exported(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
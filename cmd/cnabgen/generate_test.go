@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/HigorGrigorio/cnab"
+	"github.com/HigorGrigorio/cnab/dynamic"
+)
+
+const testLayout = `
+version: 1
+name: test
+recordLength: 30
+records:
+  - name: detalhe
+    match:
+      - {start: 1, value: "1"}
+    fields:
+      - {name: Tipo, size: 1, literal: "1"}
+      - {name: Banco, size: 3, type: int}
+      - {name: Nome do Pagador, size: 10, required: true}
+      - {name: Vencimento, start: 16, size: 6, type: date, format: "020106"}
+      - {name: Valor, size: 9, type: decimal, decimal: 2, round: half-even}
+`
+
+func TestGenerate(t *testing.T) {
+	records, err := load([]byte(testLayout), "")
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	src, err := generate("remessa", records)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	expected := `// Code generated by cnabgen. DO NOT EDIT.
+
+package remessa
+
+import (
+	"time"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+// Detalhe is the "detalhe" record of layout "test".
+type Detalhe struct {
+	Tipo  string ` + "`" + `cnab:"size:1;literal:1"` + "`" + `
+	Banco int64  ` + "`" + `cnab:"size:3;fill:0;align:right"` + "`" + `
+	// Nome do Pagador
+	NomeDoPagador string       ` + "`" + `cnab:"size:10;required"` + "`" + `
+	Vencimento    time.Time    ` + "`" + `cnab:"start:16;size:6;format:020106"` + "`" + `
+	Valor         cnab.Decimal ` + "`" + `cnab:"size:9;fill:0;align:right;decimal:2;round:half-even"` + "`" + `
+}
+`
+	if string(src) != expected {
+		t.Fatalf("unexpected source:\n%s", src)
+	}
+}
+
+// TestGenerateRoundTrip compiles the generated tags and checks that the
+// struct writes the same line as the dynamic layout.
+func TestGenerateRoundTrip(t *testing.T) {
+	records, err := load([]byte(testLayout), "")
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	src, err := generate("remessa", records)
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), "gen.go", src, 0)
+	if err != nil {
+		t.Fatalf("generated source does not parse: %v", err)
+	}
+
+	goTypes := map[string]reflect.Type{
+		"string":       reflect.TypeOf(""),
+		"int64":        reflect.TypeOf(int64(0)),
+		"float64":      reflect.TypeOf(0.0),
+		"cnab.Decimal": reflect.TypeOf(cnab.Decimal{}),
+		"time.Time":    reflect.TypeOf(time.Time{}),
+	}
+
+	var sfs []reflect.StructField
+	ast.Inspect(file, func(n ast.Node) bool {
+		if f, ok := n.(*ast.Field); ok && f.Tag != nil {
+			tag, _ := strconv.Unquote(f.Tag.Value)
+			typ := string(src[f.Type.Pos()-1 : f.Type.End()-1])
+			sfs = append(sfs, reflect.StructField{Name: f.Names[0].Name, Type: goTypes[typ], Tag: reflect.StructTag(tag)})
+		}
+		return true
+	})
+
+	fields, err := dynamic.FromStruct(reflect.New(reflect.StructOf(sfs)).Interface())
+	if err != nil {
+		t.Fatalf("FromStruct failed: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"Banco":      33,
+		"Vencimento": time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		"Valor":      "10.005",
+	}
+	original := records[0].Fields
+	want, err := dynamic.Marshal(map[string]interface{}{
+		"Banco": data["Banco"], "Nome do Pagador": "ACME",
+		"Vencimento": data["Vencimento"], "Valor": data["Valor"],
+	}, original)
+	if err != nil {
+		t.Fatalf("Marshal of layout failed: %v", err)
+	}
+	data["NomeDoPagador"] = "ACME"
+	got, err := dynamic.Marshal(data, fields)
+	if err != nil {
+		t.Fatalf("Marshal of generated layout failed: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("line mismatch:\nGot:  '%s'\nWant: '%s'", got, want)
+	}
+}
+
+func TestLoadFieldList(t *testing.T) {
+	records, err := load([]byte(`[{"name": "id", "size": 5, "type": "int"}]`), "Item")
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Name != "Item" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	if _, err := load([]byte(`[]`), ""); err == nil {
+		t.Fatal("expected error without -type")
+	}
+	if name := goName(records[0].Fields[0]); name != "Id" {
+		t.Fatalf("expected exported name Id, got %s", name)
+	}
+
+	for _, doc := range []string{
+		`[{"name": "id", "sise": 5}]`,
+		`[{"name": "id", "size": 0}]`,
+	} {
+		if _, err := load([]byte(doc), "Item"); !errors.Is(err, dynamic.ErrInvalidLayout) {
+			t.Errorf("%s: expected ErrInvalidLayout, got %v", doc, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Command cnabgen generates tagged Go structs from dynamic layout files.
+//
+// The input is either a layout document (JSON or YAML, see
+// dynamic.LoadLayout), which produces one struct per record, or a JSON
+// array of dynamic.Field, which produces the struct named by -type:
+//
+//	//go:generate go run github.com/HigorGrigorio/cnab/cmd/cnabgen -layout remessa.json -o remessa_gen.go
+//	//go:generate go run github.com/HigorGrigorio/cnab/cmd/cnabgen -layout detalhe.json -type Detalhe -o detalhe_gen.go
+//
+// The package name defaults to $GOPACKAGE, which go generate sets.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/HigorGrigorio/cnab/dynamic"
+)
+
+func main() {
+	layoutPath := flag.String("layout", "", "layout file (JSON or YAML)")
+	typeName := flag.String("type", "", "struct name for a JSON array of fields")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package name of the generated file")
+	output := flag.String("o", "", "output file (default stdout)")
+	flag.Parse()
+
+	if err := run(*layoutPath, *typeName, *pkg, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "cnabgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(layoutPath, typeName, pkg, output string) error {
+	if layoutPath == "" || pkg == "" {
+		return fmt.Errorf("-layout and -package are required")
+	}
+
+	data, err := os.ReadFile(layoutPath)
+	if err != nil {
+		return err
+	}
+
+	records, err := load(data, typeName)
+	if err != nil {
+		return fmt.Errorf("%s: %w", layoutPath, err)
+	}
+
+	src, err := generate(pkg, records)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(output, src, 0o644)
+}
+
+// load reads a layout document or a JSON array of fields.
+func load(data []byte, typeName string) ([]record, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if typeName == "" {
+			return nil, fmt.Errorf("-type is required for a list of fields")
+		}
+		fields, err := dynamic.LoadFields(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []record{{Name: typeName, Fields: fields}}, nil
+	}
+
+	l, err := dynamic.LoadLayout(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]record, len(l.Records))
+	for i, rec := range l.Records {
+		name := exported(identifier(rec.Name))
+		doc := fmt.Sprintf("%s is the %q record", name, rec.Name)
+		if l.Name != "" {
+			doc += fmt.Sprintf(" of layout %q", l.Name)
+		}
+		records[i] = record{Name: name, Doc: doc + ".", Fields: rec.Fields}
+	}
+	return records, nil
+}
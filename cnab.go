@@ -24,27 +24,71 @@ func Unmarshal(data []byte, v interface{}) error {
 }
 
 // Encoder provides CNAB encoding for struct values using field tags.
-type Encoder struct{}
+type Encoder struct {
+	norm    *Normalizer
+	charset *Charset
+}
 
 // NewEncoder creates a new CNAB encoder with default settings.
 func NewEncoder() *Encoder {
 	return &Encoder{}
 }
 
+// SetNormalizer makes Encode normalize every string field with n, except
+// fields tagged `normalize:-`. Fields tagged `normalize` use
+// NewNormalizer's settings when no Normalizer is set.
+func (e *Encoder) SetNormalizer(n *Normalizer) {
+	e.norm = n
+}
+
+// SetCharset sets the charset of the bytes returned by Encode. Without
+// one, output is UTF-8.
+func (e *Encoder) SetCharset(cs *Charset) {
+	e.charset = cs
+}
+
 // Encode marshals the provided value into CNAB-formatted bytes.
+// Fields are positioned and sized by character.
 func (e *Encoder) Encode(v interface{}) ([]byte, error) {
-	return encode(v)
+	data, err := encode(v, e.norm)
+	if err != nil || e.charset == nil {
+		return data, err
+	}
+	return e.charset.Encode(string(data))
 }
 
 // Decoder provides CNAB decoding for tagged struct values.
-type Decoder struct{}
+type Decoder struct {
+	collect bool
+	charset *Charset
+}
 
 // NewDecoder creates a new CNAB decoder with default settings.
 func NewDecoder() *Decoder {
 	return &Decoder{}
 }
 
+// CollectErrors makes Decode continue past failing fields. Every field that
+// parses is still populated and the failures are returned as an ErrorList.
+func (d *Decoder) CollectErrors() {
+	d.collect = true
+}
+
+// SetCharset sets the charset of the data given to Decode. Without one,
+// data is taken as UTF-8 as is.
+func (d *Decoder) SetCharset(cs *Charset) {
+	d.charset = cs
+}
+
 // Decode parses CNAB-formatted data into the provided destination value.
+// Fields are positioned by character.
 func (d *Decoder) Decode(data []byte, v interface{}) error {
-	return decode(data, v)
+	s := string(data)
+	if d.charset != nil {
+		var err error
+		if s, err = d.charset.Decode(data); err != nil {
+			return err
+		}
+	}
+	return decode(newChars(s), v, d.collect)
 }
@@ -0,0 +1,497 @@
+package cnab
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// codec is the compiled layout of a tagged struct type.
+type codec struct {
+	fields   []*codecField
+	repeats  []*codecField // repeated fields, holders before the repeats they contain
+	totals   []*codecField // aggregate fields filled by the Writer
+	numbers  []*codecField // numeric fields that trailers may sum
+	seqs     []*codecField // record numbers filled by the Writer
+	width    int           // highest end position
+	overlap  *codecField   // first field overlapping a previous one, if any
+	position int           // first overlapping position
+	needAddr bool          // some field has a pointer-receiver Marshaler
+}
+
+// codecField is a tagged field with its resolved interval and converters.
+type codecField struct {
+	name   string // path from the record, e.g. Pagador.Endereco.CEP
+	path   []step // steps from the record to the value
+	typ    reflect.Type
+	tag    fieldTag
+	start  int // 1-based
+	end    int // 1-based, inclusive
+	format formatFunc
+	parse  parseFunc
+	codes  map[string]*Code // enum codes by trimmed value, nil without enum
+	blank  string           // text of a nil pointer; non-empty only for pointer fields
+	occurs [][2]int         // bounds in codec.fields of each occurrence of a repeated field
+	text   bool             // string field, subject to normalization
+}
+
+// fieldError builds a FieldError describing f.
+func (f *codecField) fieldError(err error, raw string) *FieldError {
+	return &FieldError{Field: f.name, Type: f.typ, Start: f.start, End: f.end, Raw: raw, Err: err}
+}
+
+var codecCache sync.Map // map[reflect.Type]*codec
+
+// codecFor returns the cached codec of struct type t, compiling it on first use.
+// Compilation errors are not cached, so each caller gets its own error value.
+func codecFor(t reflect.Type) (*codec, error) {
+	if c, ok := codecCache.Load(t); ok {
+		return c.(*codec), nil
+	}
+
+	c, err := compile(t)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := codecCache.LoadOrStore(t, c)
+	return actual.(*codec), nil
+}
+
+// compile parses the tags of t and resolves the interval and converters of
+// each field, returning the first field error found.
+func compile(t reflect.Type) (*codec, error) {
+	c, errs := build(t)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	c.findOverlap()
+	return c, nil
+}
+
+// build compiles every usable field of t following the sequential
+// positioning rules, collecting the errors of fields that cannot be used.
+func build(t reflect.Type) (*codec, ErrorList) {
+	c := &codec{}
+	var errs ErrorList
+	c.buildStruct(t, 0, nil, "", &errs)
+	return c, errs
+}
+
+// buildStruct compiles the fields of struct type t placed after offset,
+// reached from the record through path and named after prefix. Nested
+// structs are flattened: their positions are relative to their block. It
+// returns the furthest position used.
+func (c *codec) buildStruct(t reflect.Type, offset int, path []step, prefix string, errs *ErrorList) int {
+	nextPos := offset
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tagValue := sf.Tag.Get("cnab")
+		if tagValue == "-" {
+			continue
+		}
+
+		f := &codecField{name: prefix + sf.Name, path: appendStep(path, step{index: i}), typ: sf.Type}
+
+		var end int
+		var err error
+		switch {
+		case isRepeat(sf.Type, tagValue):
+			end, err = c.buildRepeat(f, tagValue, offset, nextPos, sf.IsExported(), errs)
+		case isGroup(sf.Type) && (tagValue != "" || sf.Anonymous && sf.IsExported()):
+			end, err = c.buildGroup(f, tagValue, offset, nextPos, sf.IsExported(), errs)
+		case tagValue != "":
+			end, err = c.buildField(f, tagValue, offset, nextPos, sf.IsExported())
+		}
+		if err != nil {
+			*errs = append(*errs, f.fieldError(err, ""))
+		}
+		if end > nextPos {
+			nextPos = end
+		}
+	}
+
+	return nextPos
+}
+
+// buildGroup flattens the nested struct field f and returns the end of
+// its block.
+func (c *codec) buildGroup(f *codecField, tagValue string, offset, nextPos int, exported bool, errs *ErrorList) (int, error) {
+	gt, err := parseGroupTag(tagValue)
+	if err != nil {
+		return 0, err
+	}
+	if gt.repeat != 0 || gt.omitBlank {
+		return 0, fmt.Errorf("%w: repeat on a non-repeated field", ErrInvalidTag)
+	}
+	if !exported {
+		return 0, fmt.Errorf("%w: unexported nested struct", ErrUnsupportedType)
+	}
+
+	base := nextPos
+	if gt.start > 0 {
+		base = offset + gt.start - 1
+	}
+	return c.buildStruct(f.typ, base, f.path, f.name+".", errs), nil
+}
+
+// buildRepeat lays out the occurrences of the slice or array field f
+// contiguously and returns the end of the last one.
+func (c *codec) buildRepeat(f *codecField, tagValue string, offset, nextPos int, exported bool, errs *ErrorList) (int, error) {
+	elem := f.typ.Elem()
+	group := isGroup(elem)
+
+	var tag fieldTag
+	var err error
+	if group {
+		var gt groupTag
+		gt, err = parseGroupTag(tagValue)
+		tag = fieldTag{start: gt.start, repeat: gt.repeat, omitBlank: gt.omitBlank}
+	} else {
+		tag, err = parseTag(tagValue)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if f.typ.Kind() == reflect.Array {
+		if tag.repeat != 0 && tag.repeat != f.typ.Len() {
+			return 0, fmt.Errorf("%w: repeat %d on array of %d", ErrInvalidTag, tag.repeat, f.typ.Len())
+		}
+		if tag.omitBlank {
+			return 0, fmt.Errorf("%w: omitblank on array", ErrInvalidTag)
+		}
+		tag.repeat = f.typ.Len()
+	}
+	if tag.start < 0 || !group && tag.size <= 0 {
+		return 0, ErrInvalidTag
+	}
+	if !exported {
+		return 0, fmt.Errorf("%w: unexported repeated field", ErrUnsupportedType)
+	}
+
+	base := nextPos
+	if tag.start > 0 {
+		base = offset + tag.start - 1
+	}
+	f.tag, f.start = tag, base+1
+
+	c.repeats = append(c.repeats, f)
+	end := base
+	for k := 0; k < tag.repeat; k++ {
+		occ := &codecField{
+			name: fmt.Sprintf("%s[%d]", f.name, k),
+			path: appendStep(f.path, step{index: k, count: tag.repeat}),
+			typ:  elem,
+		}
+		first := len(c.fields)
+		if group {
+			// Later occurrences repeat the errors of the first one
+			occErrs := errs
+			if k > 0 {
+				occErrs = new(ErrorList)
+			}
+			end = c.buildStruct(elem, end, occ.path, occ.name+".", occErrs)
+			if end == base {
+				return 0, fmt.Errorf("%w: empty repeated struct", ErrInvalidTag)
+			}
+			f.occurs = append(f.occurs, [2]int{first, len(c.fields)})
+			continue
+		}
+
+		occTag := tag
+		occTag.start, occTag.repeat, occTag.omitBlank = 0, 0, false
+		occ.tag, occ.start, occ.end = occTag, end+1, end+tag.size
+		if err := c.addField(occ, true); err != nil {
+			return 0, err
+		}
+		end = occ.end
+		f.occurs = append(f.occurs, [2]int{first, len(c.fields)})
+	}
+
+	f.end = end
+	return end, nil
+}
+
+// buildField positions the single field f and returns its end.
+func (c *codec) buildField(f *codecField, tagValue string, offset, nextPos int, exported bool) (int, error) {
+	tag, err := parseTag(tagValue)
+	if err != nil {
+		return 0, err
+	}
+	if tag.repeat != 0 || tag.omitBlank {
+		return 0, fmt.Errorf("%w: repeat on a non-repeated field", ErrInvalidTag)
+	}
+	f.tag = tag
+
+	f.start = offset + tag.start
+	if tag.start == 0 {
+		f.start = nextPos + 1
+	}
+	f.end = f.start + tag.size - 1
+
+	if tag.start < 0 || tag.size <= 0 {
+		return 0, ErrInvalidTag
+	}
+	return f.end, c.addField(f, exported)
+}
+
+// addField resolves the converters of the positioned field f and adds it
+// to the codec.
+func (c *codec) addField(f *codecField, exported bool) error {
+	tag := &f.tag
+
+	if n := runeCount(tag.blank); n > 1 && n != tag.size {
+		return fmt.Errorf("%w: blank %q must be one character or %d long", ErrInvalidTag, tag.blank, tag.size)
+	}
+	if f.typ.Kind() == reflect.Ptr && exported {
+		f.blank = blankText(tag)
+	}
+
+	if tag.enum != nil {
+		codes, err := enumIndex(tag.enum, tag)
+		if err != nil {
+			return err
+		}
+		f.codes = codes
+	}
+
+	if exported {
+		f.format, f.parse = formatterFor(f.typ), parserFor(f.typ)
+	}
+	if f.format == nil || f.parse == nil {
+		if tag.literalValue == "" {
+			return ErrUnsupportedType
+		}
+		// Literals are always encoded from the tag; unexported
+		// literal fields (fillers) are skipped on decode.
+		f.parse = parseUnsupported
+		if !exported {
+			f.parse = parseSkip
+		}
+	}
+	if !f.typ.Implements(marshalerType) && reflect.PointerTo(f.typ).Implements(marshalerType) {
+		c.needAddr = true
+	}
+
+	elem := f.typ
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	f.text = exported && elem.Kind() == reflect.String && !marshals(elem)
+	if tag.normalize != "" && !f.text {
+		return fmt.Errorf("%w: normalize on a non-string field", ErrInvalidTag)
+	}
+
+	if tag.aggregate() || tag.seq != "" {
+		if tag.count != "" && tag.sum != "" || tag.aggregate() && tag.seq != "" {
+			return fmt.Errorf("%w: count, sum and seq are exclusive", ErrInvalidTag)
+		}
+		if !exported || !numeric(f.typ) {
+			return fmt.Errorf("%w: count, sum or seq on a non-numeric field", ErrInvalidTag)
+		}
+		if tag.seq != "" {
+			c.seqs = append(c.seqs, f)
+		} else {
+			c.totals = append(c.totals, f)
+		}
+	} else if exported && tag.literalValue == "" && numeric(f.typ) && !marshals(elem) {
+		// Fields with their own encoding are left out, as it need not be a number
+		c.numbers = append(c.numbers, f)
+	}
+
+	c.fields = append(c.fields, f)
+	if f.end > c.width {
+		c.width = f.end
+	}
+	return nil
+}
+
+// marshals reports whether t, or a pointer to it, has its own encoding.
+func marshals(t reflect.Type) bool {
+	return t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType)
+}
+
+// isGroup reports whether t is a struct flattened into its parent rather
+// than a single field, i.e. a struct without its own encoding.
+func isGroup(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && formatterFor(t) == nil
+}
+
+// isRepeat reports whether a field of type t tagged with tag holds
+// occurrences encoded one after another: a tagged array, or a slice with
+// a repeat count.
+func isRepeat(t reflect.Type, tag string) bool {
+	if tag == "" || formatterFor(t) != nil {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Array:
+		return true
+	case reflect.Slice:
+		for _, part := range strings.Split(tag, ";") {
+			if key, _, _ := strings.Cut(part, ":"); strings.TrimSpace(key) == "repeat" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// groupTag holds the options of a nested struct field.
+type groupTag struct {
+	start     int
+	repeat    int
+	omitBlank bool
+}
+
+// parseGroupTag parses the tag of a nested struct field, which accepts an
+// optional start, the repeat options and the "inline" marker.
+func parseGroupTag(tag string) (groupTag, error) {
+	var gt groupTag
+	for _, part := range strings.Split(tag, ";") {
+		key, value, _ := strings.Cut(part, ":")
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "", "inline":
+		case "omitblank":
+			gt.omitBlank = true
+		case "start", "repeat":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return gt, fmt.Errorf("%w: invalid %s %q", ErrInvalidTag, strings.TrimSpace(key), value)
+			}
+			if strings.TrimSpace(key) == "start" {
+				gt.start = n
+			} else {
+				gt.repeat = n
+			}
+		default:
+			return gt, fmt.Errorf("%w: %q not allowed on nested struct", ErrInvalidTag, key)
+		}
+	}
+	return gt, nil
+}
+
+// step is one move from a struct value towards a field: a struct field
+// index or, when count is set, an occurrence of a repeated field.
+type step struct {
+	index int
+	count int // occurrences of the repeated field; zero for struct fields
+}
+
+// appendStep returns a copy of path followed by s.
+func appendStep(path []step, s step) []step {
+	steps := make([]step, len(path)+1)
+	copy(steps, path)
+	steps[len(path)] = s
+	return steps
+}
+
+// value returns the value of f within the struct rv. It reports false
+// for an occurrence missing from a short slice.
+func (f *codecField) value(rv reflect.Value) (reflect.Value, bool) {
+	for _, s := range f.path {
+		if s.count == 0 {
+			rv = rv.Field(s.index)
+			continue
+		}
+		if s.index >= rv.Len() {
+			return reflect.Value{}, false
+		}
+		rv = rv.Index(s.index)
+	}
+	return rv, true
+}
+
+// target returns the settable value of f within the struct rv, sizing
+// repeated slices to their full count on the way.
+func (f *codecField) target(rv reflect.Value) reflect.Value {
+	for _, s := range f.path {
+		if s.count == 0 {
+			rv = rv.Field(s.index)
+			continue
+		}
+		if rv.Kind() == reflect.Slice && rv.Len() != s.count {
+			rv.Set(reflect.MakeSlice(rv.Type(), s.count, s.count))
+		}
+		rv = rv.Index(s.index)
+	}
+	return rv
+}
+
+// blankText returns the text written for a nil pointer field: the blank
+// pattern, repeated when it is a single character, or the fill character.
+func blankText(tag *fieldTag) string {
+	switch runeCount(tag.blank) {
+	case 0:
+		return strings.Repeat(string(tag.fill), tag.size)
+	case 1:
+		return strings.Repeat(tag.blank, tag.size)
+	}
+	return tag.blank
+}
+
+// findOverlap records the first field that claims a position already
+// taken by a previous field. Overlaps are reported by encode only.
+func (c *codec) findOverlap() {
+	used := make([]bool, c.width)
+	for _, f := range c.fields {
+		for pos := f.start; pos <= f.end; pos++ {
+			if used[pos-1] {
+				c.overlap, c.position = f, pos
+				return
+			}
+			used[pos-1] = true
+		}
+	}
+}
+
+// overlapError returns the error for the overlap found at compile time.
+func (c *codec) overlapError() error {
+	return c.overlap.fieldError(fmt.Errorf("%w at position %d", ErrFieldOverlap, c.position), "")
+}
+
+// Codec encodes and decodes values of struct type T with a layout that is
+// compiled once, avoiding per-call tag parsing.
+type Codec[T any] struct {
+	c *codec
+}
+
+// Compile builds the layout of T, reporting tag errors up front.
+func Compile[T any]() (*Codec[T], error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return nil, ErrInvalidStruct
+	}
+
+	c, err := codecFor(t)
+	if err != nil {
+		return nil, err
+	}
+	return &Codec[T]{c: c}, nil
+}
+
+// Encode returns the CNAB encoding of v.
+func (c *Codec[T]) Encode(v *T) ([]byte, error) {
+	return c.Append(nil, v)
+}
+
+// Append appends the CNAB encoding of v to dst, allowing callers to reuse buffers.
+func (c *Codec[T]) Append(dst []byte, v *T) ([]byte, error) {
+	if v == nil {
+		return nil, ErrInvalidStruct
+	}
+	return c.c.encode(dst, reflect.ValueOf(v).Elem(), nil)
+}
+
+// Decode parses data into v.
+func (c *Codec[T]) Decode(data []byte, v *T) error {
+	if v == nil {
+		return ErrInvalidStructPtr
+	}
+	return c.c.decode(data, reflect.ValueOf(v).Elem(), false)
+}
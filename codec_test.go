@@ -0,0 +1,129 @@
+package cnab
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompileRoundTrip(t *testing.T) {
+	c, err := Compile[TestHeader]()
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	h := TestHeader{
+		Code:   1,
+		Name:   "TEST",
+		Date:   time.Date(2023, 10, 25, 0, 0, 0, 0, time.UTC),
+		Amount: 123.45,
+	}
+
+	data, err := c.Encode(&h)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	expected := "001TEST      202310250000012345        "
+	if string(data) != expected {
+		t.Fatalf("Encode mismatch:\nGot:  '%s'\nWant: '%s'", string(data), expected)
+	}
+
+	var out TestHeader
+	if err := c.Decode(data, &out); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if out != h {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+
+	// Append reuses the destination buffer.
+	buf := make([]byte, 0, 2*len(expected))
+	buf, _ = c.Append(buf, &h)
+	buf, _ = c.Append(buf, &h)
+	if string(buf) != expected+expected {
+		t.Fatalf("unexpected Append result: '%s'", string(buf))
+	}
+
+	if _, err := c.Encode(nil); !errors.Is(err, ErrInvalidStruct) {
+		t.Fatalf("expected ErrInvalidStruct for nil, got %v", err)
+	}
+	if err := c.Decode(data, nil); !errors.Is(err, ErrInvalidStructPtr) {
+		t.Fatalf("expected ErrInvalidStructPtr for nil, got %v", err)
+	}
+}
+
+func TestCompileTagError(t *testing.T) {
+	type BadTag struct {
+		Code int `cnab:"size:abc"`
+	}
+
+	_, err := Compile[BadTag]()
+	if !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag at compile time, got %v", err)
+	}
+
+	type BadType struct {
+		Flags []int `cnab:"size:3"`
+	}
+
+	_, err = Compile[BadType]()
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "Flags" || !errors.Is(err, ErrUnsupportedType) {
+		t.Fatalf("expected ErrUnsupportedType for Flags, got %v", err)
+	}
+
+	if _, err := Compile[int](); !errors.Is(err, ErrInvalidStruct) {
+		t.Fatalf("expected ErrInvalidStruct, got %v", err)
+	}
+}
+
+func TestCodecCache(t *testing.T) {
+	typ := reflect.TypeOf(TestHeader{})
+
+	c1, err := codecFor(typ)
+	if err != nil {
+		t.Fatalf("codecFor failed: %v", err)
+	}
+	c2, _ := codecFor(typ)
+	if c1 != c2 {
+		t.Fatalf("expected cached codec to be reused")
+	}
+}
+
+func TestUnexportedLiteralFiller(t *testing.T) {
+	type WithFiller struct {
+		Code   int    `cnab:"size:3;fill:0;align:right"`
+		filler string `cnab:"literal:XX"`
+	}
+
+	data, err := Marshal(WithFiller{Code: 7})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != "007XX" {
+		t.Fatalf("expected '007XX', got '%s'", string(data))
+	}
+
+	var out WithFiller
+	if err := Unmarshal(data, &out); err != nil || out.Code != 7 {
+		t.Fatalf("Unmarshal failed: %v (%+v)", err, out)
+	}
+}
+
+func BenchmarkCodecDecode(b *testing.B) {
+	c, err := Compile[TestHeader]()
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := []byte("001TEST      202310250000012345        ")
+
+	var h TestHeader
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := c.Decode(data, &h); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
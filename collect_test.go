@@ -0,0 +1,90 @@
+package cnab
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecoderCollectErrors(t *testing.T) {
+	input := "0X1TEST      2023102500000A2345        "
+	var h TestHeader
+
+	d := NewDecoder()
+	d.CollectErrors()
+	err := d.Decode([]byte(input), &h)
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %T: %v", err, err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(list), err)
+	}
+
+	var fe *FieldError
+	if !errors.As(list[1], &fe) || fe.Field != "Amount" {
+		t.Fatalf("expected Amount field error, got %v", list[1])
+	}
+	if !errors.Is(err, ErrInvalidNumberFormat) {
+		t.Fatalf("expected ErrInvalidNumberFormat in list, got %v", err)
+	}
+
+	// Fields that parsed are still populated.
+	if h.Name != "TEST" || h.Date.IsZero() {
+		t.Fatalf("expected valid fields to be populated, got %+v", h)
+	}
+}
+
+func TestReaderCollectErrors(t *testing.T) {
+	input := "0BANK      \n" +
+		"1000X0\n" +
+		"9XXXXX\n" +
+		"1000Y0\n" +
+		"100030\n"
+
+	r := newTestReader(input)
+	r.CollectErrors()
+
+	records, err := r.ReadAll()
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %T: %v", err, err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(list), err)
+	}
+	if !errors.Is(list[1], ErrUnknownRecord) {
+		t.Fatalf("expected unknown record error, got %v", list[1])
+	}
+
+	var fe *FieldError
+	if !errors.As(list[2], &fe) || fe.Line != 4 {
+		t.Fatalf("expected field error on line 4, got %v", list[2])
+	}
+
+	// The unknown record is skipped; partially decoded records are kept.
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+	if d := records[3].Value.(*readerDetail); d.Amount != 30 {
+		t.Fatalf("expected last detail to be decoded, got %+v", d)
+	}
+}
+
+func TestReadFile240CollectErrors(t *testing.T) {
+	input := strings.Replace(testFile240, "P000150", "P0001X0", 1)
+	input = strings.Replace(input, "00199999000000000001\r\n", "", 1)
+
+	r := NewReader(strings.NewReader(input))
+	r.CollectErrors()
+	f, err := r.ReadFile240(testLayout240)
+
+	if !errors.Is(err, ErrInvalidNumberFormat) || !errors.Is(err, ErrFileStructure) {
+		t.Fatalf("expected number and structure errors, got %v", err)
+	}
+	if f == nil || len(f.Lots) != 1 || len(f.Lots[0].Details) != 2 {
+		t.Fatalf("expected partial file tree, got %+v", f)
+	}
+}
@@ -0,0 +1,264 @@
+package cnab
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode selects how digits are dropped when a Decimal is rescaled.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to nearest, ties away from zero.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to nearest, ties to the even neighbour (banker's rounding).
+	RoundHalfEven
+	// RoundDown truncates toward zero.
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor
+	// RoundCeiling rounds toward positive infinity.
+	RoundCeiling
+)
+
+var roundingModes = map[string]RoundingMode{
+	"half-up":   RoundHalfUp,
+	"half-even": RoundHalfEven,
+	"down":      RoundDown,
+	"up":        RoundUp,
+	"floor":     RoundFloor,
+	"ceiling":   RoundCeiling,
+}
+
+// ParseRoundingMode returns the mode named by s ("half-up", "half-even",
+// "down", "up", "floor" or "ceiling"). An empty name means RoundHalfUp.
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	if s == "" {
+		return RoundHalfUp, nil
+	}
+	mode, ok := roundingModes[s]
+	if !ok {
+		return RoundHalfUp, fmt.Errorf("%w: unknown rounding mode %q", ErrInvalidTag, s)
+	}
+	return mode, nil
+}
+
+// String returns the name of m as accepted by ParseRoundingMode.
+func (m RoundingMode) String() string {
+	for name, mode := range roundingModes {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("RoundingMode(%d)", int(m))
+}
+
+// Decimal is an exact decimal number: an arbitrary-precision coefficient
+// scaled by 10^-scale. The zero value is 0. Decimals are immutable.
+type Decimal struct {
+	coef  *big.Int // nil means zero
+	scale int
+}
+
+// NewDecimal returns unscaled * 10^-scale, e.g. NewDecimal(12345, 2) is 123.45.
+func NewDecimal(unscaled int64, scale int) Decimal {
+	if scale < 0 {
+		scale = 0
+	}
+	return Decimal{coef: big.NewInt(unscaled), scale: scale}
+}
+
+// ParseDecimal parses a number in plain decimal notation, such as "-123.45".
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	digits := s
+	scale := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits = s[:i] + s[i+1:]
+		scale = len(s) - i - 1
+	}
+
+	if digits == "" || strings.ContainsAny(digits, "_.") {
+		return Decimal{}, ErrInvalidNumberFormat
+	}
+	coef, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, ErrInvalidNumberFormat
+	}
+	return Decimal{coef: coef, scale: scale}, nil
+}
+
+// NewDecimalFromFloat converts f using its shortest decimal representation,
+// so 0.1 becomes exactly 0.1.
+func NewDecimalFromFloat(f float64) (Decimal, error) {
+	return decimalFromFloat(f, 64)
+}
+
+// decimalFromFloat converts f using the shortest representation that
+// round-trips at the given bit size (32 or 64).
+func decimalFromFloat(f float64, bitSize int) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, ErrInvalidNumberFormat
+	}
+	return ParseDecimal(strconv.FormatFloat(f, 'f', -1, bitSize))
+}
+
+// ParseImplicitDecimal parses a CNAB numeric field whose last scale digits
+// are decimals, e.g. "0000012345" with scale 2 is 123.45. Text with an
+// explicit decimal point is parsed as is.
+func ParseImplicitDecimal(s string, scale int) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ".") {
+		return ParseDecimal(s)
+	}
+	coef, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return Decimal{}, ErrInvalidNumberFormat
+	}
+	return Decimal{coef: coef, scale: scale}, nil
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Unscaled returns a copy of the coefficient, e.g. 12345 for 123.45.
+func (d Decimal) Unscaled() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(d.coef)
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d.
+func (d Decimal) Sign() int {
+	if d.coef == nil {
+		return 0
+	}
+	return d.coef.Sign()
+}
+
+// IsZero reports whether d is zero, regardless of scale.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Rescale returns d with exactly scale digits after the decimal point,
+// rounding with mode when digits are dropped.
+func (d Decimal) Rescale(scale int, mode RoundingMode) Decimal {
+	if scale < 0 {
+		scale = 0
+	}
+	coef := d.Unscaled()
+
+	if scale >= d.scale {
+		coef.Mul(coef, pow10(scale-d.scale))
+		return Decimal{coef: coef, scale: scale}
+	}
+
+	div := pow10(d.scale - scale)
+	q, r := new(big.Int).QuoRem(coef, div, new(big.Int))
+	if r.Sign() != 0 && roundAway(q, r, div, coef.Sign(), mode) {
+		q.Add(q, big.NewInt(int64(coef.Sign())))
+	}
+	return Decimal{coef: q, scale: scale}
+}
+
+// roundAway reports whether the truncated quotient q must move one unit
+// away from zero, given the remainder r of the division by div.
+func roundAway(q, r, div *big.Int, sign int, mode RoundingMode) bool {
+	switch mode {
+	case RoundDown:
+		return false
+	case RoundUp:
+		return true
+	case RoundFloor:
+		return sign < 0
+	case RoundCeiling:
+		return sign > 0
+	}
+
+	twice := new(big.Int).Abs(r)
+	twice.Lsh(twice, 1)
+	switch twice.Cmp(div) {
+	case -1:
+		return false
+	case 1:
+		return true
+	}
+	if mode == RoundHalfEven {
+		return q.Bit(0) == 1
+	}
+	return true
+}
+
+// Add returns d + o at the larger of both scales.
+func (d Decimal) Add(o Decimal) Decimal {
+	scale := d.scale
+	if o.scale > scale {
+		scale = o.scale
+	}
+	a := d.Rescale(scale, RoundDown)
+	b := o.Rescale(scale, RoundDown)
+	a.coef.Add(a.coef, b.coef)
+	return a
+}
+
+// Cmp compares d and o, returning -1, 0 or +1.
+func (d Decimal) Cmp(o Decimal) int {
+	scale := d.scale
+	if o.scale > scale {
+		scale = o.scale
+	}
+	return d.Rescale(scale, RoundDown).coef.Cmp(o.Rescale(scale, RoundDown).coef)
+}
+
+// Float64 returns the nearest float64 to d.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// String formats d in plain decimal notation, keeping its scale (e.g. "123.40").
+func (d Decimal) String() string {
+	s := d.Unscaled().String()
+	if d.scale == 0 {
+		return s
+	}
+
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if len(s) <= d.scale {
+		s = strings.Repeat("0", d.scale-len(s)+1) + s
+	}
+	s = s[:len(s)-d.scale] + "." + s[len(s)-d.scale:]
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Decimal) UnmarshalText(text []byte) error {
+	v, err := ParseDecimal(string(text))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,94 @@
+package cnab
+
+import (
+	"testing"
+)
+
+func TestDecimalRescale(t *testing.T) {
+	tests := []struct {
+		in   string
+		mode RoundingMode
+		want string
+	}{
+		{"1.005", RoundHalfUp, "1.01"},
+		{"1.005", RoundHalfEven, "1.00"},
+		{"1.015", RoundHalfEven, "1.02"},
+		{"-1.005", RoundHalfUp, "-1.01"},
+		{"1.009", RoundDown, "1.00"},
+		{"1.001", RoundUp, "1.01"},
+		{"-1.001", RoundFloor, "-1.01"},
+		{"-1.009", RoundCeiling, "-1.00"},
+		{"7", RoundHalfUp, "7.00"},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDecimal(tt.in)
+		if err != nil {
+			t.Fatalf("ParseDecimal(%q) failed: %v", tt.in, err)
+		}
+		if got := d.Rescale(2, tt.mode).String(); got != tt.want {
+			t.Errorf("Rescale(%s, %d) = %s, want %s", tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a := NewDecimal(10, 1) // 1.0
+	b, _ := ParseDecimal("0.25")
+
+	sum := a.Add(b)
+	if sum.String() != "1.25" {
+		t.Errorf("expected 1.25, got %s", sum)
+	}
+	if sum.Cmp(NewDecimal(125, 2)) != 0 || a.Cmp(b) != 1 {
+		t.Errorf("unexpected comparison results")
+	}
+	if NewDecimal(-5, 3).String() != "-0.005" {
+		t.Errorf("expected -0.005, got %s", NewDecimal(-5, 3))
+	}
+
+	if _, err := ParseDecimal("1.2.3"); err == nil {
+		t.Errorf("expected error for invalid decimal")
+	}
+}
+
+func TestDecimalField(t *testing.T) {
+	type Amounts struct {
+		Total   Decimal `cnab:"size:15;decimal:2;fill:0;align:right"`
+		Rate    Decimal `cnab:"size:8;decimal:4;fill:0;align:right;round:half-even"`
+		Float   float64 `cnab:"size:6;decimal:2;fill:0;align:right"`
+		Missing Decimal `cnab:"size:5;decimal:2;fill:0;align:right"`
+	}
+
+	total, _ := ParseDecimal("12345678901.23")
+	rate, _ := ParseDecimal("0.123450")
+	s := Amounts{Total: total, Rate: rate, Float: 1.005}
+
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	// 1.005 is scaled exactly instead of through float multiplication.
+	expected := "001234567890123" + "00001234" + "000101" + "00000"
+	if string(data) != expected {
+		t.Fatalf("Marshal mismatch:\nGot:  '%s'\nWant: '%s'", string(data), expected)
+	}
+
+	var out Amounts
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.Total.String() != "12345678901.23" {
+		t.Errorf("expected Total 12345678901.23, got %s", out.Total)
+	}
+	if out.Rate.String() != "0.1234" {
+		t.Errorf("expected Rate 0.1234, got %s", out.Rate)
+	}
+	if out.Float != 1.01 {
+		t.Errorf("expected Float 1.01, got %v", out.Float)
+	}
+	if !out.Missing.IsZero() || out.Missing.Scale() != 2 {
+		t.Errorf("expected zero Missing with scale 2, got %s", out.Missing)
+	}
+}
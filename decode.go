@@ -9,7 +9,7 @@ import (
 	"time"
 )
 
-func decode(data []byte, v interface{}) error {
+func decode(line chars, v interface{}, collect bool) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return ErrInvalidStructPtr
@@ -20,147 +20,300 @@ func decode(data []byte, v interface{}) error {
 		return ErrInvalidStruct
 	}
 
-	line := string(data)
+	c, err := codecFor(rv.Type())
+	if err != nil {
+		return err
+	}
+	return c.decodeChars(line, rv, collect)
+}
 
-	currentPos := 0
-	t := rv.Type()
+// decode parses data into the struct value rv. In collect mode every field
+// is attempted and the failures are returned as an ErrorList.
+func (c *codec) decode(data []byte, rv reflect.Value, collect bool) error {
+	return c.decodeChars(newChars(string(data)), rv, collect)
+}
 
-	for i := 0; i < rv.NumField(); i++ {
-		field := t.Field(i)
-		tagValue := field.Tag.Get("cnab")
-		if tagValue == "" {
-			continue
-		}
+// decodeChars decodes line, already converted to UTF-8, into rv.
+func (c *codec) decodeChars(line chars, rv reflect.Value, collect bool) error {
+	var errs ErrorList
 
-		tag, err := parseTag(tagValue)
-		if err != nil {
-			return fmt.Errorf("field %s: %w", field.Name, err)
+	for _, f := range c.fields {
+		if fe := f.decode(f.target(rv), line); fe != nil {
+			if !collect {
+				return fe
+			}
+			errs = append(errs, fe)
 		}
+	}
 
-		// Determine start and end positions (1-based in tags)
-		start := tag.start
-		if start == 0 {
-			start = currentPos + 1
+	// Inner repeats come last, so they are trimmed before their holders
+	for i := len(c.repeats) - 1; i >= 0; i-- {
+		if r := c.repeats[i]; r.tag.omitBlank {
+			trimBlank(r.target(rv), c.used(r, line))
 		}
-		end := start + tag.size - 1
-		if tag.end > 0 {
-			end = tag.end
+	}
+
+	return errs.err()
+}
+
+// used returns the number of occurrences of the repeated field r up to
+// the last one holding a value in line. Literals do not count as values.
+func (c *codec) used(r *codecField, line chars) int {
+	for n := len(r.occurs); n > 0; n-- {
+		occ := r.occurs[n-1]
+		for _, f := range c.fields[occ[0]:occ[1]] {
+			if f.tag.literalValue != "" || f.end > line.len() {
+				continue
+			}
+			if s := line.slice(f.start, f.end); s != f.blank && !isBlank(s, f.tag.fill) {
+				return n
+			}
 		}
+	}
+	return 0
+}
+
+// trimBlank cuts the slice v to its first n occurrences, leaving nil when
+// none is left.
+func trimBlank(v reflect.Value, n int) {
+	if n > v.Len() {
+		return
+	}
+	if n == 0 {
+		v.Set(reflect.Zero(v.Type()))
+	} else {
+		v.Set(v.Slice(0, n))
+	}
+}
 
-		if end > len(line) {
-			return ErrLineTooShort
+// decode parses the value of a single field from line.
+func (f *codecField) decode(v reflect.Value, line chars) *FieldError {
+	if f.end > line.len() {
+		raw := ""
+		if f.start <= line.len() {
+			raw = line.slice(f.start, line.len())
 		}
+		return f.fieldError(ErrLineTooShort, raw)
+	}
 
-		valStr := line[start-1 : end]
-		// Update currentPos for next field if using sequential
-		if end > currentPos {
-			currentPos = end
+	valStr := line.slice(f.start, f.end)
+	// Blank pointer fields decode to nil
+	if f.blank != "" && (valStr == f.blank || isBlank(valStr, f.tag.fill)) {
+		if f.tag.required {
+			return f.fieldError(ErrRequired, valStr)
 		}
+		v.Set(reflect.Zero(f.typ))
+		return nil
+	}
 
-		err = setFieldValue(rv.Field(i), valStr, tag)
-		if err != nil {
-			return fmt.Errorf("field %s: %w", field.Name, err)
+	if f.tag.required && isBlank(valStr, f.tag.fill) {
+		return f.fieldError(ErrRequired, valStr)
+	}
+
+	if f.codes != nil {
+		if err := f.checkCode(valStr); err != nil {
+			return f.fieldError(err, valStr)
 		}
 	}
 
+	if err := f.parse(v, valStr, &f.tag); err != nil {
+		return f.fieldError(err, valStr)
+	}
+
 	return nil
 }
 
-func setFieldValue(v reflect.Value, s string, tag fieldTag) error {
-	// Check for Unmarshaler interface
-	// v is likely addressable since it comes from rv.Field(i) of a pointer struct
-	if v.CanAddr() {
-		addr := v.Addr()
-		if u, ok := addr.Interface().(Unmarshaler); ok {
-			return u.UnmarshalCNAB([]byte(s))
+// isBlank reports whether s holds only fill characters or spaces.
+func isBlank(s string, fill rune) bool {
+	for _, r := range s {
+		if r != fill && r != ' ' {
+			return false
 		}
 	}
+	return true
+}
 
-	// Also check if the value itself implements Unmarshaler (unlikely for pointer receivers, but possible)
-	if v.CanInterface() {
-		if u, ok := v.Interface().(Unmarshaler); ok {
-			return u.UnmarshalCNAB([]byte(s))
-		}
+// parseFunc stores the CNAB text s into v.
+type parseFunc func(v reflect.Value, s string, tag *fieldTag) error
+
+// parserFor returns the parser for values of type t,
+// or nil when t cannot be decoded.
+func parserFor(t reflect.Type) parseFunc {
+	if t.Kind() == reflect.Ptr {
+		return parsePointer(t, parserFor(t.Elem()))
+	}
+
+	switch {
+	case reflect.PointerTo(t).Implements(unmarshalerType):
+		return parseAddrUnmarshaler
+	case t.Implements(unmarshalerType):
+		return parseUnmarshaler
+	case t == decimalType:
+		return parseDecimalValue
+	case t == timeType:
+		return parseTime
 	}
 
-	switch v.Kind() {
+	switch t.Kind() {
 	case reflect.String:
-		if tag.align == "right" {
-			s = strings.TrimLeft(s, string(tag.fill))
-		} else {
-			s = strings.TrimRight(s, string(tag.fill))
-		}
-		v.SetString(s)
+		return parseString
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		s = strings.TrimSpace(s)
-		if s == "" {
-			v.SetInt(0)
-			return nil
-		}
-		val, err := strconv.ParseInt(s, 10, 64)
-		if err != nil {
-			return err
-		}
-		v.SetInt(val)
+		return parseInt
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		s = strings.TrimSpace(s)
-		if s == "" {
-			v.SetUint(0)
+		return parseUint
+	case reflect.Float32, reflect.Float64:
+		return parseFloat
+	case reflect.Bool:
+		return parseBool
+	}
+	return nil
+}
+
+// parsePointer parses into the value pointed to with elem, allocating it
+// when the pointer is nil.
+func parsePointer(t reflect.Type, elem parseFunc) parseFunc {
+	if elem == nil {
+		return nil
+	}
+	return func(v reflect.Value, s string, tag *fieldTag) error {
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return elem(v.Elem(), s, tag)
+	}
+}
+
+func parseAddrUnmarshaler(v reflect.Value, s string, _ *fieldTag) error {
+	return v.Addr().Interface().(Unmarshaler).UnmarshalCNAB([]byte(s))
+}
+
+func parseUnmarshaler(v reflect.Value, s string, _ *fieldTag) error {
+	return v.Interface().(Unmarshaler).UnmarshalCNAB([]byte(s))
+}
+
+func parseUnsupported(reflect.Value, string, *fieldTag) error {
+	return ErrUnsupportedType
+}
+
+func parseSkip(reflect.Value, string, *fieldTag) error {
+	return nil
+}
+
+func parseString(v reflect.Value, s string, tag *fieldTag) error {
+	if tag.align == "right" {
+		s = strings.TrimLeft(s, string(tag.fill))
+	} else {
+		s = strings.TrimRight(s, string(tag.fill))
+	}
+	v.SetString(s)
+	return nil
+}
+
+func parseInt(v reflect.Value, s string, _ *fieldTag) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		v.SetInt(0)
+		return nil
+	}
+	val, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return ErrInvalidNumberFormat
+	}
+	v.SetInt(val)
+	return nil
+}
+
+func parseUint(v reflect.Value, s string, _ *fieldTag) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		v.SetUint(0)
+		return nil
+	}
+	val, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return ErrInvalidNumberFormat
+	}
+	v.SetUint(val)
+	return nil
+}
+
+func parseFloat(v reflect.Value, s string, tag *fieldTag) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		v.SetFloat(0)
+		return nil
+	}
+
+	if tag.decimal > 0 {
+		// Integers below 2^53 and powers of ten up to 1e22 are exact in
+		// float64, so a single division is correctly rounded.
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && tag.decimal <= 22 && n > -1<<53 && n < 1<<53 {
+			v.SetFloat(float64(n) / math.Pow10(tag.decimal))
 			return nil
 		}
-		val, err := strconv.ParseUint(s, 10, 64)
+		d, err := ParseImplicitDecimal(s, tag.decimal)
 		if err != nil {
 			return err
 		}
-		v.SetUint(val)
-	case reflect.Float32, reflect.Float64:
-		s = strings.TrimSpace(s)
-		if s == "" {
-			v.SetFloat(0)
-			return nil
-		}
+		v.SetFloat(d.Float64())
+		return nil
+	}
 
-		if tag.decimal > 0 {
-			// Parse as int first, then divide
-			valInt, err := strconv.ParseInt(s, 10, 64)
-			if err != nil {
-				// Fallback to float parsing if it has a dot?
-				f, err2 := strconv.ParseFloat(s, 64)
-				if err2 == nil {
-					v.SetFloat(f)
-					return nil
-				}
-				return err
-			}
-			f := float64(valInt) / math.Pow(10, float64(tag.decimal))
-			v.SetFloat(f)
-		} else {
-			val, err := strconv.ParseFloat(s, 64)
-			if err != nil {
-				return err
-			}
-			v.SetFloat(val)
-		}
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return ErrInvalidNumberFormat
+	}
+	v.SetFloat(val)
+	return nil
+}
 
-	case reflect.Struct:
-		if v.Type() == reflect.TypeOf(time.Time{}) {
-			s = strings.TrimSpace(s)
-			if s == "" || s == strings.Repeat(string(tag.fill), len(s)) {
-				// Empty time
-				return nil
-			}
-			f := tag.format
-			if f == "" {
-				f = "20060102"
-			}
-			t, err := time.Parse(f, s)
-			if err != nil {
-				return ErrInvalidDateFormat
-			}
-			v.Set(reflect.ValueOf(t))
-		}
+// parseBool accepts only the tag's true and false codes.
+func parseBool(v reflect.Value, s string, tag *fieldTag) error {
+	if tag.align == "right" {
+		s = strings.TrimLeft(s, string(tag.fill))
+	} else {
+		s = strings.TrimRight(s, string(tag.fill))
+	}
+	t, f := tag.boolCodes()
+	switch strings.TrimSpace(s) {
+	case t:
+		v.SetBool(true)
+	case f:
+		v.SetBool(false)
 	default:
-		return ErrUnsupportedType
+		return fmt.Errorf("%w: want %q or %q", ErrInvalidBool, t, f)
+	}
+	return nil
+}
+
+func parseDecimalValue(v reflect.Value, s string, tag *fieldTag) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		v.Set(reflect.ValueOf(Decimal{scale: tag.decimal}))
+		return nil
+	}
+	d, err := ParseImplicitDecimal(s, tag.decimal)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(d))
+	return nil
+}
+
+func parseTime(v reflect.Value, s string, tag *fieldTag) error {
+	s = strings.TrimSpace(s)
+	if s == "" || s == strings.Repeat(string(tag.fill), len(s)) {
+		// Empty time
+		return nil
+	}
+	f := tag.format
+	if f == "" {
+		f = "20060102"
+	}
+	t, err := time.Parse(f, s)
+	if err != nil {
+		return ErrInvalidDateFormat
 	}
+	v.Set(reflect.ValueOf(t))
 	return nil
 }
@@ -0,0 +1,191 @@
+package cnab
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FieldSpec is the resolved layout of one tagged struct field, as
+// compiled from its `cnab` tag.
+type FieldSpec struct {
+	Name      string // path of nested fields, e.g. Pagador.Endereco.CEP or Mensagens[1]
+	Type      reflect.Type
+	Start     int // 1-based; zero in Tag means right after the previous field
+	End       int // 1-based, inclusive
+	Size      int
+	Fill      rune
+	Align     string // "left" or "right"
+	Format    string
+	Decimal   int
+	Literal   string
+	Required  bool
+	Rounding  RoundingMode
+	True      string // bool code for true; empty means "S"
+	False     string // bool code for false; empty means "N"
+	Enum      string // code set name or inline list, see ParseCodeSet
+	Blank     string // text of nil pointers; empty means all fill
+	Count     string // aggregate filled by the Writer: CountRecords or CountLots
+	Sum       string // aggregate filled by the Writer: "Type.Field" or "Field"
+	Seq       string // record numbering filled by the Writer: SeqFile or SeqLot
+	SeqStart  int    // first record number; Describe reports 1 unless the tag sets it
+	Normalize string // "on" forces the default Normalizer, "-" disables it; empty follows the Encoder
+}
+
+// Describe returns the compiled layout of v, which may be a struct value or
+// a pointer to one, with every field at its resolved position.
+func Describe(v interface{}) ([]FieldSpec, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, ErrInvalidStruct
+	}
+
+	c, err := codecFor(t)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := make([]FieldSpec, len(c.fields))
+	for i, f := range c.fields {
+		specs[i] = FieldSpec{
+			Name:      f.name,
+			Type:      f.typ,
+			Start:     f.start,
+			End:       f.end,
+			Size:      f.end - f.start + 1,
+			Fill:      f.tag.fill,
+			Align:     f.tag.align,
+			Format:    f.tag.format,
+			Decimal:   f.tag.decimal,
+			Literal:   f.tag.literalValue,
+			Required:  f.tag.required,
+			Rounding:  f.tag.rounding,
+			True:      f.tag.trueCode,
+			False:     f.tag.falseCode,
+			Enum:      f.tag.enumSpec,
+			Blank:     f.tag.blank,
+			Count:     f.tag.count,
+			Sum:       f.tag.sum,
+			Seq:       f.tag.seq,
+			SeqStart:  f.tag.seqStart,
+			Normalize: f.tag.normalize,
+		}
+	}
+	return specs, nil
+}
+
+// Values returns the values of the fields of v, a struct or a pointer to
+// one, in the order of Describe. Unexported fields and occurrences missing
+// from short slices are nil.
+func Values(v interface{}) ([]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, ErrInvalidStruct
+	}
+
+	c, err := codecFor(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(c.fields))
+	for i, f := range c.fields {
+		if val, ok := f.value(rv); ok && val.CanInterface() {
+			values[i] = val.Interface()
+		}
+	}
+	return values, nil
+}
+
+// Tag returns the `cnab` struct tag value that compiles back to s. Default
+// options are omitted. It fails with ErrInvalidTag when s cannot be written
+// as a tag, e.g. a literal containing ';' or surrounding spaces.
+func (s FieldSpec) Tag() (string, error) {
+	if s.Size <= 0 || s.Start < 0 || s.Decimal < 0 {
+		return "", ErrInvalidTag
+	}
+	if strings.ContainsRune(s.Literal, ';') || strings.TrimSpace(s.Literal) != s.Literal ||
+		strings.ContainsRune(s.Format, ';') || strings.TrimSpace(s.Format) != s.Format {
+		return "", ErrInvalidTag
+	}
+	for _, code := range []string{s.True, s.False, s.Enum, s.Blank, s.Count, s.Sum, s.Seq} {
+		if strings.ContainsRune(code, ';') || strings.TrimSpace(code) != code {
+			return "", ErrInvalidTag
+		}
+	}
+
+	var parts []string
+	if s.Start > 0 {
+		parts = append(parts, "start:"+strconv.Itoa(s.Start))
+	}
+	parts = append(parts, "size:"+strconv.Itoa(s.Size))
+
+	switch {
+	case s.Fill == 0 || s.Fill == ' ':
+	case s.Fill == ';' || unicode.IsSpace(s.Fill):
+		return "", ErrInvalidTag
+	default:
+		parts = append(parts, "fill:"+string(s.Fill))
+	}
+	if s.Align == "right" {
+		parts = append(parts, "align:right")
+	}
+	if s.Format != "" {
+		parts = append(parts, "format:"+s.Format)
+	}
+	if s.Decimal > 0 {
+		parts = append(parts, "decimal:"+strconv.Itoa(s.Decimal))
+	}
+	if s.Rounding != RoundHalfUp {
+		parts = append(parts, "round:"+s.Rounding.String())
+	}
+	if s.True != "" {
+		parts = append(parts, "true:"+s.True)
+	}
+	if s.False != "" {
+		parts = append(parts, "false:"+s.False)
+	}
+	if s.Enum != "" {
+		parts = append(parts, "enum:"+s.Enum)
+	}
+	if s.Blank != "" {
+		parts = append(parts, "blank:"+s.Blank)
+	}
+	if s.Count != "" {
+		parts = append(parts, "count:"+s.Count)
+	}
+	if s.Sum != "" {
+		parts = append(parts, "sum:"+s.Sum)
+	}
+	switch {
+	case s.Seq == "":
+	case s.SeqStart == 1:
+		parts = append(parts, "seq:"+s.Seq)
+	default:
+		parts = append(parts, "seq:"+s.Seq+","+strconv.Itoa(s.SeqStart))
+	}
+	switch s.Normalize {
+	case "":
+	case "on":
+		parts = append(parts, "normalize")
+	case "-":
+		parts = append(parts, "normalize:-")
+	default:
+		return "", ErrInvalidTag
+	}
+	if s.Literal != "" {
+		parts = append(parts, "literal:"+s.Literal)
+	}
+	if s.Required {
+		parts = append(parts, "required")
+	}
+
+	return strings.Join(parts, ";"), nil
+}
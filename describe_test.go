@@ -0,0 +1,73 @@
+package cnab
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type describeRecord struct {
+	Kind   string    `cnab:"literal:1"`
+	Bank   int       `cnab:"start:2;end:4;fill:0;align:right"`
+	Name   string    `cnab:"size:10;required"`
+	Date   time.Time `cnab:"start:20;size:6;format:020106"`
+	Amount Decimal   `cnab:"size:8;fill:0;align:right;decimal:2;round:half-even"`
+	Rate   float64   `cnab:"size:5;fill:*;decimal:3"`
+	Active bool      `cnab:"true:A;false:I"`
+}
+
+func TestDescribe(t *testing.T) {
+	specs, err := Describe(&describeRecord{})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+
+	want := FieldSpec{Name: "Amount", Type: decimalType, Start: 26, End: 33, Size: 8, Fill: '0',
+		Align: "right", Decimal: 2, Rounding: RoundHalfEven}
+	if !reflect.DeepEqual(specs[4], want) {
+		t.Fatalf("unexpected spec:\nGot:  %+v\nWant: %+v", specs[4], want)
+	}
+
+	if _, err := Describe(10); !errors.Is(err, ErrInvalidStruct) {
+		t.Fatalf("expected ErrInvalidStruct, got %v", err)
+	}
+}
+
+func TestFieldSpecTagRoundTrip(t *testing.T) {
+	specs, err := Describe(describeRecord{})
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+
+	fields := make([]reflect.StructField, len(specs))
+	for i, s := range specs {
+		tag, err := s.Tag()
+		if err != nil {
+			t.Fatalf("field %s: Tag failed: %v", s.Name, err)
+		}
+		fields[i] = reflect.StructField{Name: s.Name, Type: s.Type, Tag: reflect.StructTag(`cnab:"` + tag + `"`)}
+	}
+
+	again, err := Describe(reflect.New(reflect.StructOf(fields)).Interface())
+	if err != nil {
+		t.Fatalf("Describe of generated struct failed: %v", err)
+	}
+	if !reflect.DeepEqual(specs, again) {
+		t.Fatalf("round trip mismatch:\nGot:  %+v\nWant: %+v", again, specs)
+	}
+}
+
+func TestFieldSpecTagInvalid(t *testing.T) {
+	for _, s := range []FieldSpec{
+		{Size: 0},
+		{Size: 3, Literal: "A;B"},
+		{Size: 3, Literal: " A"},
+		{Size: 3, Fill: ';'},
+		{Size: 3, Fill: '\t'},
+	} {
+		if _, err := s.Tag(); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("expected ErrInvalidTag for %+v, got %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package dynamic
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+// ErrInvalidMapping indicates a mapping that does not fit its layout or CSV.
+var ErrInvalidMapping = errors.New("dynamic: invalid mapping")
+
+// RecordMapping fills the fields of a layout record. Fields maps a field
+// name to an expression; detail fields left out are read from the CSV
+// column of the same name, when there is one.
+//
+// Expressions name CSV columns directly (Nome) or in brackets for other
+// characters ([Nome do Pagador]), quote constants ('237', "A") and may call
+// upper, lower, trim, digits, concat, default(value, fallback),
+// substr(value, start, length), line() (the CNAB record number), count()
+// (details written so far) and sum(Field) (total of a numeric detail field
+// so far). Header and trailer expressions cannot read columns; in the
+// trailer, count and sum cover the whole file.
+type RecordMapping struct {
+	Record string // record name in the Layout; empty writes no record
+	Fields map[string]string
+}
+
+// Converter turns a CSV stream with a header row into a CNAB file: an
+// optional header record, one detail record per row and an optional trailer.
+type Converter struct {
+	Layout  *Layout
+	Header  RecordMapping
+	Detail  RecordMapping
+	Trailer RecordMapping
+	Comma   rune // CSV field delimiter; zero means ','
+
+	// Normalizer normalizes the fields with Normalize set; nil uses
+	// cnab.NewNormalizer's settings.
+	Normalizer *cnab.Normalizer
+}
+
+// RowError is a failure to convert one CSV row.
+type RowError struct {
+	Line int // CSV line number, starting at 1
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("csv line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// mapping is a compiled RecordMapping.
+type mapping struct {
+	fields Fields
+	exprs  []expr // nil for fields left blank
+	sums   []string
+}
+
+// Convert reads every row of r and writes the records to w, padding them
+// to Layout.RecordLength, then flushes w. Rows that fail are skipped and
+// reported together as a cnab.ErrorList of *RowError once the trailer is
+// written; any other error stops the conversion.
+func (c *Converter) Convert(r io.Reader, w *cnab.Writer) error {
+	if c.Layout == nil {
+		return fmt.Errorf("%w: no layout", ErrInvalidMapping)
+	}
+
+	cr := csv.NewReader(r)
+	if c.Comma != 0 {
+		cr.Comma = c.Comma
+	}
+	names, err := cr.Read()
+	if err == io.EOF {
+		return fmt.Errorf("%w: missing CSV header row", ErrInvalidMapping)
+	}
+	if err != nil {
+		return err
+	}
+
+	columns := make(map[string]int, len(names))
+	for i, name := range names {
+		columns[strings.TrimPrefix(strings.TrimSpace(name), "\ufeff")] = i
+	}
+
+	detail, err := c.compile(c.Detail, columns)
+	if err != nil {
+		return err
+	}
+	if detail == nil {
+		return fmt.Errorf("%w: no detail record", ErrInvalidMapping)
+	}
+	header, err := c.compile(c.Header, nil)
+	if err != nil {
+		return err
+	}
+	trailer, err := c.compile(c.Trailer, nil)
+	if err != nil {
+		return err
+	}
+	for _, m := range []*mapping{header, trailer} {
+		if err := checkSums(m, detail.fields); err != nil {
+			return err
+		}
+	}
+	needSums := header != nil && len(header.sums) > 0 || trailer != nil && len(trailer.sums) > 0
+
+	ctx := &evalContext{columns: columns, sums: make(map[string]cnab.Decimal)}
+	written := 0
+	write := func(m *mapping) error {
+		ctx.line = written + 1
+		line, err := m.record(ctx, c.Layout.RecordLength, c.Normalizer)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteLine(line); err != nil {
+			return err
+		}
+		written++
+		return nil
+	}
+
+	if header != nil {
+		if err := write(header); err != nil {
+			return fmt.Errorf("header: %w", err)
+		}
+	}
+
+	var errs cnab.ErrorList
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			errs = append(errs, &RowError{Line: pe.StartLine, Err: pe.Err})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		lineNo, _ := cr.FieldPos(0)
+		ctx.row = row
+		ctx.line = written + 1
+		line, err := detail.record(ctx, c.Layout.RecordLength, c.Normalizer)
+		if err == nil && needSums {
+			err = detail.addSums(line, ctx.sums)
+		}
+		if err != nil {
+			errs = append(errs, &RowError{Line: lineNo, Err: err})
+			continue
+		}
+		if err := w.WriteLine(line); err != nil {
+			return err
+		}
+		written++
+		ctx.count++
+	}
+
+	ctx.row = nil
+	if trailer != nil {
+		if err := write(trailer); err != nil {
+			return fmt.Errorf("trailer: %w", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// compile resolves the record of rm and parses its expressions. Column
+// references are checked against columns, so a nil map rejects them.
+func (c *Converter) compile(rm RecordMapping, columns map[string]int) (*mapping, error) {
+	if rm.Record == "" {
+		return nil, nil
+	}
+	rec := c.Layout.Record(rm.Record)
+	if rec == nil {
+		return nil, fmt.Errorf("%w: unknown record %q", ErrInvalidMapping, rm.Record)
+	}
+
+	m := &mapping{fields: rec.Fields, exprs: make([]expr, len(rec.Fields))}
+	known := make(map[string]bool, len(rec.Fields))
+	for i, f := range rec.Fields {
+		known[f.Name] = true
+
+		src, ok := rm.Fields[f.Name]
+		if !ok {
+			if _, found := columns[f.Name]; found {
+				m.exprs[i] = column{f.Name}
+			}
+			continue
+		}
+
+		e, err := parseExpr(src)
+		if err == nil {
+			err = walk(e, func(e expr) error {
+				switch e := e.(type) {
+				case column:
+					if columns == nil {
+						return fmt.Errorf("%w: column %s is only available in detail records", ErrInvalidMapping, e.name)
+					}
+					if _, found := columns[e.name]; !found {
+						return fmt.Errorf("%w: unknown column %q", ErrInvalidMapping, e.name)
+					}
+				case sum:
+					m.sums = append(m.sums, e.field)
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %s: field %s: %w", rm.Record, f.Name, err)
+		}
+		m.exprs[i] = e
+	}
+
+	for name := range rm.Fields {
+		if !known[name] {
+			return nil, fmt.Errorf("%w: record %s has no field %s", ErrInvalidMapping, rm.Record, name)
+		}
+	}
+	return m, nil
+}
+
+// checkSums verifies that the fields summed by m are numeric detail fields.
+func checkSums(m *mapping, detail Fields) error {
+	if m == nil {
+		return nil
+	}
+	for _, name := range m.sums {
+		found := false
+		for _, f := range detail {
+			if f.Name == name && f.numeric() {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: sum(%s) needs a numeric detail field", ErrInvalidMapping, name)
+		}
+	}
+	return nil
+}
+
+// record evaluates the expressions of m and marshals the record.
+func (m *mapping) record(ctx *evalContext, recordLength int, norm *cnab.Normalizer) ([]byte, error) {
+	data := make(map[string]interface{}, len(m.fields))
+	for i, e := range m.exprs {
+		if e == nil {
+			continue
+		}
+		s, err := e.eval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", m.fields[i].Name, err)
+		}
+		data[m.fields[i].Name] = s
+	}
+
+	line, err := MarshalNormalized(data, m.fields, norm)
+	if err != nil {
+		return nil, err
+	}
+	n := utf8.RuneCount(line)
+	if n > recordLength {
+		return nil, fmt.Errorf("%w: got %d, want %d", cnab.ErrRecordLength, n, recordLength)
+	}
+	return append(line, bytes.Repeat([]byte(" "), recordLength-n)...), nil
+}
+
+// addSums adds the numeric values written in line to sums. Values are read
+// back from the line so that totals match the rounded amounts in the file.
+func (m *mapping) addSums(line []byte, sums map[string]cnab.Decimal) error {
+	data, err := Unmarshal(line, m.fields)
+	if err != nil {
+		return err
+	}
+	for _, f := range m.fields {
+		if !f.numeric() {
+			continue
+		}
+		var d cnab.Decimal
+		switch v := data[f.Name].(type) {
+		case int64:
+			d = cnab.NewDecimal(v, 0)
+		case float64:
+			if d, err = cnab.NewDecimalFromFloat(v); err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		case cnab.Decimal:
+			d = v
+		}
+		sums[f.Name] = sums[f.Name].Add(d)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package dynamic
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+const convertLayout = `
+version: 1
+recordLength: 30
+records:
+  - name: header
+    fields:
+      - {name: Type, size: 1, literal: "0"}
+      - {name: Bank, size: 3, type: int}
+      - {name: Company, size: 10}
+  - name: detail
+    fields:
+      - {name: Type, size: 1, literal: "1"}
+      - {name: Seq, size: 4, type: int}
+      - {name: Name, size: 10, required: true}
+      - {name: Document, size: 6}
+      - {name: Amount, size: 8, type: decimal, decimal: 2}
+  - name: trailer
+    fields:
+      - {name: Type, size: 1, literal: "9"}
+      - {name: Count, size: 4, type: int}
+      - {name: Total, size: 10, type: decimal, decimal: 2}
+      - {name: Records, size: 4, type: int}
+`
+
+func newTestConverter(t *testing.T) *Converter {
+	t.Helper()
+	l, err := LoadLayout(strings.NewReader(convertLayout))
+	if err != nil {
+		t.Fatalf("LoadLayout failed: %v", err)
+	}
+	return &Converter{
+		Layout: l,
+		Header: RecordMapping{Record: "header", Fields: map[string]string{
+			"Bank":    "237",
+			"Company": "upper('acme')",
+		}},
+		Detail: RecordMapping{Record: "detail", Fields: map[string]string{
+			"Seq":      "line()",
+			"Name":     "upper(trim([Nome do Cliente]))",
+			"Document": "substr(digits(cpf), 1, 6)",
+			"Amount":   "default(valor, '0')",
+		}},
+		Trailer: RecordMapping{Record: "trailer", Fields: map[string]string{
+			"Count":   "count()",
+			"Total":   "sum(Amount)",
+			"Records": "line()",
+		}},
+	}
+}
+
+func TestConvert(t *testing.T) {
+	c := newTestConverter(t)
+
+	csvData := "Nome do Cliente,cpf,valor\n" +
+		" ana ,123.456.789-00,10.50\n" +
+		"bruno,987.654.321-00,\n" +
+		"carla,111.222.333-44,0.005\n"
+
+	var buf bytes.Buffer
+	w := cnab.NewWriter(&buf)
+	w.SetLineEnding(cnab.LF)
+	w.SetRecordLength(30)
+
+	if err := c.Convert(strings.NewReader(csvData), w); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+
+	expected := "0237ACME                      \n" +
+		"10002ANA       12345600001050 \n" +
+		"10003BRUNO     98765400000000 \n" +
+		"10004CARLA     11122200000001 \n" +
+		"9000300000010510005           \n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected output:\nGot:\n%s\nWant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestConvertByCharacter(t *testing.T) {
+	c := newTestConverter(t)
+	c.Detail.Fields["Name"] = "trim([Nome do Cliente])"
+
+	csvData := "Nome do Cliente,cpf,valor\n" +
+		"João,1,1.00\n"
+
+	var buf bytes.Buffer
+	w := cnab.NewWriter(&buf)
+	w.SetLineEnding(cnab.LF)
+	w.SetRecordLength(30)
+
+	if err := c.Convert(strings.NewReader(csvData), w); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if lines := strings.Split(buf.String(), "\n"); lines[1] != "10002João      1     00000100 " {
+		t.Fatalf("unexpected detail: %q", lines[1])
+	}
+
+	c.Layout.RecordLength = 20
+	err := c.Convert(strings.NewReader(csvData), cnab.NewWriter(&buf))
+	if !errors.Is(err, cnab.ErrRecordLength) {
+		t.Fatalf("expected ErrRecordLength, got %v", err)
+	}
+}
+
+func TestConvertNormalizer(t *testing.T) {
+	c := newTestConverter(t)
+	c.Detail.Fields["Name"] = "[Nome do Cliente]"
+	c.Layout.Record("detail").Fields[2].Normalize = true
+	c.Normalizer = &cnab.Normalizer{Transliterate: true, Upper: true, Allowed: "ABCDEFGHIJKLMNOPQRSTUVWXYZ ", Replacement: '-'}
+
+	var buf bytes.Buffer
+	w := cnab.NewWriter(&buf)
+	w.SetLineEnding(cnab.LF)
+	if err := c.Convert(strings.NewReader("Nome do Cliente,cpf,valor\nZé d'Ávila,1,1.00\n"), w); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if lines := strings.Split(buf.String(), "\n"); !strings.HasPrefix(lines[1], "10002ZE D-AVILA") {
+		t.Fatalf("unexpected detail: %q", lines[1])
+	}
+}
+
+func TestConvertRowErrors(t *testing.T) {
+	c := newTestConverter(t)
+
+	csvData := "Nome do Cliente,cpf,valor\n" +
+		"ana,1,1.00\n" +
+		",2,2.00\n" + // Name is required
+		"carla,3,abc\n" + // invalid amount
+		"\"unterminated,4,4.00\n"
+
+	var buf bytes.Buffer
+	err := c.Convert(strings.NewReader(csvData), cnab.NewWriter(&buf))
+
+	var list cnab.ErrorList
+	if !errors.As(err, &list) || len(list) != 3 {
+		t.Fatalf("expected 3 row errors, got %v", err)
+	}
+
+	lines := []int{3, 4, 5}
+	for i, e := range list {
+		var re *RowError
+		if !errors.As(e, &re) || re.Line != lines[i] {
+			t.Errorf("error %d: expected RowError at line %d, got %v", i, lines[i], e)
+		}
+	}
+	if !errors.Is(list[0], cnab.ErrRequired) {
+		t.Errorf("expected ErrRequired, got %v", list[0])
+	}
+
+	// The valid row is still written, and the trailer counts it alone.
+	if n := strings.Count(buf.String(), "\r\n"); n != 3 {
+		t.Fatalf("expected 3 records, got %d:\n%s", n, buf.String())
+	}
+}
+
+func TestConvertInvalidMapping(t *testing.T) {
+	for _, m := range []map[string]string{
+		{"Name": "upper(missing)"},
+		{"Name": "nope(cpf)"},
+		{"Name": "upper(cpf"},
+		{"Name": "substr(cpf, 1)"},
+		{"Unknown": "cpf"},
+	} {
+		c := newTestConverter(t)
+		c.Detail.Fields = m
+
+		err := c.Convert(strings.NewReader("Nome do Cliente,cpf,valor\n"), cnab.NewWriter(&bytes.Buffer{}))
+		if !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("%v: expected ErrInvalidMapping, got %v", m, err)
+		}
+	}
+
+	c := newTestConverter(t)
+	c.Trailer.Fields["Total"] = "sum(Name)"
+	if err := c.Convert(strings.NewReader("Nome do Cliente,cpf,valor\n"), cnab.NewWriter(&bytes.Buffer{})); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("expected ErrInvalidMapping for non-numeric sum, got %v", err)
+	} else if !strings.Contains(err.Error(), "sum(Name)") {
+		t.Errorf("expected sum error, got %v", err)
+	}
+
+	c = newTestConverter(t)
+	c.Header.Fields["Company"] = "cpf"
+	if err := c.Convert(strings.NewReader("Nome do Cliente,cpf,valor\n"), cnab.NewWriter(&bytes.Buffer{})); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("expected ErrInvalidMapping for column in header, got %v", err)
+	} else if !strings.Contains(err.Error(), "only available in detail records") {
+		t.Errorf("expected header column error, got %v", err)
+	}
+}
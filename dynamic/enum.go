@@ -0,0 +1,49 @@
+package dynamic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+// findCode returns the entry of the Enum set matching the field text s,
+// comparing both without padding so "1" and "01" match in numeric fields.
+// Blank values yield a nil entry and no error.
+func (f Field) findCode(s string) (*cnab.Code, error) {
+	set, err := cnab.ParseCodeSet(f.Enum)
+	if err != nil {
+		return nil, err
+	}
+
+	code := trimValue(s, f)
+	if code == "" {
+		return nil, nil
+	}
+	for i, c := range set.Codes {
+		if trimValue(c.Code, f) == code {
+			return &set.Codes[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q: allowed %s", cnab.ErrInvalidCode, strings.TrimSpace(s), set)
+}
+
+// Label returns the label of the code v in the field's Enum set.
+// v may be a value returned by Unmarshal or any value accepted by Marshal.
+func (f Field) Label(v interface{}) (string, error) {
+	if f.Enum == "" {
+		return "", fmt.Errorf("field %s: %w: no enum", f.Name, cnab.ErrInvalidTag)
+	}
+	s, err := formatValue(v, f)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	c, err := f.findCode(s)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	if c == nil {
+		return "", nil
+	}
+	return c.Label, nil
+}
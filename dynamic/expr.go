@@ -0,0 +1,262 @@
+package dynamic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+// expr is a compiled mapping expression.
+type expr interface {
+	eval(ctx *evalContext) (string, error)
+}
+
+// evalContext holds what an expression can see while a record is built.
+type evalContext struct {
+	columns map[string]int // CSV column index by header name
+	row     []string       // current CSV row
+	line    int            // number of the CNAB record being written
+	count   int            // details written so far
+	sums    map[string]cnab.Decimal
+}
+
+// column reads a CSV column of the current row.
+type column struct{ name string }
+
+func (c column) eval(ctx *evalContext) (string, error) {
+	return ctx.row[ctx.columns[c.name]], nil
+}
+
+// constant is a quoted string or a number.
+type constant struct{ value string }
+
+func (c constant) eval(*evalContext) (string, error) {
+	return c.value, nil
+}
+
+// call applies a function to its evaluated arguments.
+type call struct {
+	name string
+	args []expr
+	fn   func(ctx *evalContext, args []string) (string, error)
+}
+
+func (c call) eval(ctx *evalContext) (string, error) {
+	args := make([]string, len(c.args))
+	for i, a := range c.args {
+		s, err := a.eval(ctx)
+		if err != nil {
+			return "", err
+		}
+		args[i] = s
+	}
+	return c.fn(ctx, args)
+}
+
+// function is a built-in with its accepted argument count (-1 for any).
+type function struct {
+	arity int
+	fn    func(ctx *evalContext, args []string) (string, error)
+}
+
+var functions = map[string]function{
+	"upper": {1, func(_ *evalContext, a []string) (string, error) { return strings.ToUpper(a[0]), nil }},
+	"lower": {1, func(_ *evalContext, a []string) (string, error) { return strings.ToLower(a[0]), nil }},
+	"trim":  {1, func(_ *evalContext, a []string) (string, error) { return strings.TrimSpace(a[0]), nil }},
+	"digits": {1, func(_ *evalContext, a []string) (string, error) {
+		return strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, a[0]), nil
+	}},
+	"concat": {-1, func(_ *evalContext, a []string) (string, error) { return strings.Join(a, ""), nil }},
+	"default": {2, func(_ *evalContext, a []string) (string, error) {
+		if strings.TrimSpace(a[0]) == "" {
+			return a[1], nil
+		}
+		return a[0], nil
+	}},
+	"substr": {3, substr},
+	"line":   {0, func(ctx *evalContext, _ []string) (string, error) { return strconv.Itoa(ctx.line), nil }},
+	"count":  {0, func(ctx *evalContext, _ []string) (string, error) { return strconv.Itoa(ctx.count), nil }},
+}
+
+// substr returns len characters of s from the 1-based start, clipped to s.
+func substr(_ *evalContext, a []string) (string, error) {
+	start, err1 := strconv.Atoi(a[1])
+	n, err2 := strconv.Atoi(a[2])
+	if err1 != nil || err2 != nil || start < 1 || n < 0 {
+		return "", fmt.Errorf("substr: invalid range %s, %s", a[1], a[2])
+	}
+	r := []rune(a[0])
+	if start > len(r) {
+		return "", nil
+	}
+	end := start - 1 + n
+	if end > len(r) {
+		end = len(r)
+	}
+	return string(r[start-1 : end]), nil
+}
+
+// sum is the total of a detail field over the details written so far.
+type sum struct{ field string }
+
+func (s sum) eval(ctx *evalContext) (string, error) {
+	return ctx.sums[s.field].String(), nil
+}
+
+// parseExpr compiles a mapping expression. The grammar is:
+//
+//	expr     = call | column | constant
+//	call     = name "(" [expr {"," expr}] ")"
+//	column   = name | "[" any text "]"
+//	constant = "'" text "'" | "\"" text "\"" | number
+//
+// sum takes a detail field name instead of an expression.
+func parseExpr(s string) (expr, error) {
+	p := &exprParser{src: []rune(s)}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.space()
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos])
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	src []rune
+	pos int
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %q at %d: %s", ErrInvalidMapping, string(p.src), p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) space() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// until consumes the text up to the closing rune.
+func (p *exprParser) until(end rune) (string, error) {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != end {
+		p.pos++
+	}
+	if p.pos == len(p.src) {
+		return "", p.errorf("missing %q", end)
+	}
+	p.pos++
+	return string(p.src[start : p.pos-1]), nil
+}
+
+func (p *exprParser) expr() (expr, error) {
+	p.space()
+	if p.pos == len(p.src) {
+		return nil, p.errorf("expression expected")
+	}
+
+	r := p.src[p.pos]
+	switch {
+	case r == '\'' || r == '"':
+		p.pos++
+		s, err := p.until(r)
+		return constant{s}, err
+	case r == '[':
+		p.pos++
+		s, err := p.until(']')
+		return column{s}, err
+	case r == '-' || unicode.IsDigit(r):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && (unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return constant{string(p.src[start:p.pos])}, nil
+	case !isNameRune(r):
+		return nil, p.errorf("unexpected %q", r)
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && isNameRune(p.src[p.pos]) {
+		p.pos++
+	}
+	name := string(p.src[start:p.pos])
+
+	p.space()
+	if p.pos == len(p.src) || p.src[p.pos] != '(' {
+		return column{name}, nil
+	}
+	p.pos++
+
+	var args []expr
+	p.space()
+	for p.pos < len(p.src) && p.src[p.pos] != ')' {
+		if len(args) > 0 {
+			if p.src[p.pos] != ',' {
+				return nil, p.errorf("',' expected")
+			}
+			p.pos++
+		}
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		p.space()
+	}
+	if p.pos == len(p.src) {
+		return nil, p.errorf("missing ')'")
+	}
+	p.pos++
+
+	if name == "sum" {
+		var col column
+		ok := len(args) == 1
+		if ok {
+			col, ok = args[0].(column)
+		}
+		if !ok {
+			return nil, p.errorf("sum takes a detail field name")
+		}
+		return sum{col.name}, nil
+	}
+
+	f, ok := functions[name]
+	if !ok {
+		return nil, p.errorf("unknown function %s", name)
+	}
+	if f.arity >= 0 && len(args) != f.arity {
+		return nil, p.errorf("%s takes %d arguments", name, f.arity)
+	}
+	return call{name: name, args: args, fn: f.fn}, nil
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// walk calls fn for e and every sub-expression.
+func walk(e expr, fn func(expr) error) error {
+	if err := fn(e); err != nil {
+		return err
+	}
+	if c, ok := e.(call); ok {
+		for _, a := range c.args {
+			if err := walk(a, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
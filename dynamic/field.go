@@ -1,21 +1,164 @@
 package dynamic
 
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
 // Field describes a CNAB field definition used for dynamic layouts.
 type Field struct {
-	Name     string `json:"name"`
-	Size     int    `json:"size"`
-	Start    int    `json:"start,omitempty"` // 1-based start position
-	Required bool   `json:"required,omitempty"`
+	Name     string `json:"name" yaml:"name"`
+	Size     int    `json:"size" yaml:"size"`
+	Start    int    `json:"start,omitempty" yaml:"start,omitempty"` // 1-based start position
+	Required bool   `json:"required,omitempty" yaml:"required,omitempty"`
 
 	// Formatting options
-	Fill  string `json:"fill,omitempty"`  // Character to fill with (default ' ' or '0')
-	Align string `json:"align,omitempty"` // "left" or "right"
+	Fill  string `json:"fill,omitempty" yaml:"fill,omitempty"`   // Character to fill with (default ' ' or '0')
+	Align string `json:"align,omitempty" yaml:"align,omitempty"` // "left" or "right"
 
 	// Type specific
-	Type    string `json:"type,omitempty"`    // "string", "int", "float", "date"
-	Format  string `json:"format,omitempty"`  // Date format
-	Decimal int    `json:"decimal,omitempty"` // Decimal places for float/int
+	Type    string `json:"type,omitempty" yaml:"type,omitempty"`       // "string", "int", "float", "decimal", "date", "bool"
+	Format  string `json:"format,omitempty" yaml:"format,omitempty"`   // Date format
+	Decimal int    `json:"decimal,omitempty" yaml:"decimal,omitempty"` // Decimal places for float/decimal
+	Round   string `json:"round,omitempty" yaml:"round,omitempty"`     // Rounding mode for decimals (default "half-up")
+	Literal string `json:"literal,omitempty" yaml:"literal,omitempty"` // Constant value written regardless of the data
+	True    string `json:"true,omitempty" yaml:"true,omitempty"`       // Code for true in bool fields (default "S")
+	False   string `json:"false,omitempty" yaml:"false,omitempty"`     // Code for false in bool fields (default "N")
+	Enum    string `json:"enum,omitempty" yaml:"enum,omitempty"`       // Allowed codes: a registered set name or "01|02" (see cnab.ParseCodeSet)
+
+	// Normalize makes string values bank-safe, by default with
+	// cnab.NewNormalizer's settings: transliterated, uppercased, printable
+	// ASCII only. MarshalNormalized and Converter.Normalizer set others.
+	Normalize bool `json:"normalize,omitempty" yaml:"normalize,omitempty"`
+
+	// Trailer aggregates, filled by cnab.Writer in structs generated from
+	// the layout. Marshal writes them from data like any numeric field.
+	Count string `json:"count,omitempty" yaml:"count,omitempty"` // "records" or "lots" (see cnab.CountRecords)
+	Sum   string `json:"sum,omitempty" yaml:"sum,omitempty"`     // Numeric field summed: "Type.Field" or "Field"
+
+	// Seq numbers records in structs generated from the layout, with the
+	// syntax of the seq tag: "file" or "lot", optionally followed by the
+	// first number ("file,0").
+	Seq string `json:"seq,omitempty" yaml:"seq,omitempty"`
+
+	// Absent values
+	Optional bool   `json:"optional,omitempty" yaml:"optional,omitempty"` // Blank text unmarshals to nil
+	Blank    string `json:"blank,omitempty" yaml:"blank,omitempty"`       // Text of nil values: one character repeated or the whole field
+}
+
+// defaultNormalizer applies to fields with Normalize set when the caller
+// gives none.
+var defaultNormalizer = cnab.NewNormalizer()
+
+// numeric reports whether the field holds a number, which changes the
+// default fill ("0") and alignment ("right").
+func (f Field) numeric() bool {
+	return f.Type == "int" || f.Type == "float" || f.Type == "decimal"
+}
+
+// boolCodes returns the codes written for true and false.
+func (f Field) boolCodes() (string, string) {
+	t, fc := f.True, f.False
+	if t == "" {
+		t = "S"
+	}
+	if fc == "" {
+		fc = "N"
+	}
+	return t, fc
+}
+
+// blank returns the text written for a nil value, or "" to pad with fill.
+func (f Field) blank() string {
+	if utf8.RuneCountInString(f.Blank) == 1 {
+		return strings.Repeat(f.Blank, f.Size)
+	}
+	return f.Blank
+}
+
+// isBlankText reports whether raw is the blank text of f or holds only
+// fill characters and spaces.
+func (f Field) isBlankText(raw string) bool {
+	return (f.Blank != "" && raw == f.blank()) || strings.Trim(raw, f.fill()+" ") == ""
 }
 
 // Fields represents a collection of CNAB field definitions.
 type Fields []Field
+
+// fill returns the padding character, defaulting to "0" for numbers and " " otherwise.
+func (f Field) fill() string {
+	if f.Fill != "" {
+		return f.Fill
+	}
+	if f.numeric() {
+		return "0"
+	}
+	return " "
+}
+
+// align returns the padding direction, defaulting to "right" for numbers and "left" otherwise.
+func (f Field) align() string {
+	if f.Align != "" {
+		return f.Align
+	}
+	if f.numeric() {
+		return "right"
+	}
+	return "left"
+}
+
+// span is the resolved 1-based, inclusive interval of a field.
+type span struct {
+	start int
+	end   int
+}
+
+// spans resolves the interval of each field. Fields without Start begin
+// right after the furthest position used by the previous fields.
+func (fs Fields) spans() []span {
+	spans := make([]span, len(fs))
+	next := 0
+	for i, f := range fs {
+		start := f.Start
+		if start == 0 {
+			start = next + 1
+		}
+		spans[i] = span{start: start, end: start + f.Size - 1}
+		if spans[i].end > next {
+			next = spans[i].end
+		}
+	}
+	return spans
+}
+
+// width returns the record length covered by spans.
+func width(spans []span) int {
+	w := 0
+	for _, sp := range spans {
+		if sp.end > w {
+			w = sp.end
+		}
+	}
+	return w
+}
+
+// checkSpans rejects negative positions and fields claiming a position
+// already taken by a previous field.
+func (fs Fields) checkSpans(spans []span) error {
+	used := make([]bool, width(spans))
+	for i, sp := range spans {
+		if fs[i].Start < 0 || fs[i].Size < 0 {
+			return fmt.Errorf("field %s: %w: invalid start/size", fs[i].Name, cnab.ErrInvalidTag)
+		}
+		for pos := sp.start; pos <= sp.end; pos++ {
+			if used[pos-1] {
+				return fmt.Errorf("field %s: %w at position %d", fs[i].Name, cnab.ErrFieldOverlap, pos)
+			}
+			used[pos-1] = true
+		}
+	}
+	return nil
+}
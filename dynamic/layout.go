@@ -0,0 +1,263 @@
+package dynamic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/HigorGrigorio/cnab"
+	"gopkg.in/yaml.v3"
+)
+
+// LayoutVersion is the layout document version understood by LoadLayout.
+const LayoutVersion = 1
+
+// ErrInvalidLayout indicates that a layout document failed validation.
+var ErrInvalidLayout = errors.New("dynamic: invalid layout")
+
+// Layout describes a whole CNAB file: its record length and the record
+// types it contains.
+type Layout struct {
+	Version      int      `json:"version" yaml:"version"`
+	Name         string   `json:"name,omitempty" yaml:"name,omitempty"`
+	RecordLength int      `json:"recordLength" yaml:"recordLength"`
+	Records      []Record `json:"records" yaml:"records"`
+}
+
+// Record is a named record type. A line belongs to the first record whose
+// discriminators all match; a record without discriminators matches any line.
+type Record struct {
+	Name   string  `json:"name" yaml:"name"`
+	Match  []Match `json:"match,omitempty" yaml:"match,omitempty"`
+	Fields Fields  `json:"fields" yaml:"fields"`
+}
+
+// Match is a discriminator: the text at the 1-based Start must equal Value.
+type Match struct {
+	Start int    `json:"start" yaml:"start"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// LoadLayout reads a layout document in JSON or YAML and validates it.
+// Unknown keys are rejected so that typos in data files do not go unnoticed.
+func LoadLayout(r io.Reader) (*Layout, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var l Layout
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&l)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&l)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// LoadFields reads a list of fields in JSON or YAML and validates it as
+// LoadLayout validates a record. Unknown keys are rejected.
+func LoadFields(r io.Reader) (Fields, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var fs Fields
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&fs)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&fs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+
+	var errs cnab.ErrorList
+	for _, err := range fs.validate(0) {
+		errs = append(errs, fmt.Errorf("%w: %v", ErrInvalidLayout, err))
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return fs, nil
+}
+
+// Validate checks the layout, reporting every problem as a cnab.ErrorList
+// of errors wrapping ErrInvalidLayout.
+func (l *Layout) Validate() error {
+	var errs cnab.ErrorList
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidLayout}, args...)...))
+	}
+
+	if l.Version != LayoutVersion {
+		fail("unsupported version %d", l.Version)
+	}
+	if l.RecordLength <= 0 {
+		fail("recordLength must be positive")
+	}
+	if len(l.Records) == 0 {
+		fail("no records")
+	}
+
+	names := make(map[string]bool)
+	for _, rec := range l.Records {
+		if rec.Name == "" {
+			fail("record without name")
+		} else if names[rec.Name] {
+			fail("duplicate record %q", rec.Name)
+		}
+		names[rec.Name] = true
+
+		for _, m := range rec.Match {
+			if m.Start < 1 || m.Value == "" || m.Start+utf8.RuneCountInString(m.Value)-1 > l.RecordLength {
+				fail("record %q: invalid discriminator at %d", rec.Name, m.Start)
+			}
+		}
+
+		for _, err := range rec.Fields.validate(l.RecordLength) {
+			fail("record %q: %v", rec.Name, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate checks the field definitions against a record length.
+func (fs Fields) validate(recordLength int) []error {
+	var errs []error
+
+	names := make(map[string]bool)
+	for _, f := range fs {
+		if f.Name == "" {
+			errs = append(errs, fmt.Errorf("field without name"))
+		} else if names[f.Name] {
+			errs = append(errs, fmt.Errorf("duplicate field %q", f.Name))
+		}
+		names[f.Name] = true
+
+		if f.Size <= 0 {
+			errs = append(errs, fmt.Errorf("field %s: size must be positive", f.Name))
+		}
+		switch f.Type {
+		case "", "string", "int", "float", "decimal", "date", "bool":
+		default:
+			errs = append(errs, fmt.Errorf("field %s: unknown type %q", f.Name, f.Type))
+		}
+		if utf8.RuneCountInString(f.Literal) > f.Size {
+			errs = append(errs, fmt.Errorf("field %s: %w: literal %q longer than size %d",
+				f.Name, cnab.ErrFieldSizeMismatch, f.Literal, f.Size))
+		}
+		if t, fc := f.boolCodes(); f.Type == "bool" && t == fc {
+			errs = append(errs, fmt.Errorf("field %s: true and false codes are both %q", f.Name, t))
+		}
+		if n := utf8.RuneCountInString(f.Blank); n > 1 && n != f.Size {
+			errs = append(errs, fmt.Errorf("field %s: blank %q must be one character or %d long", f.Name, f.Blank, f.Size))
+		}
+		if utf8.RuneCountInString(f.Fill) > 1 {
+			errs = append(errs, fmt.Errorf("field %s: fill must be a single character", f.Name))
+		}
+		if f.Align != "" && f.Align != "left" && f.Align != "right" {
+			errs = append(errs, fmt.Errorf("field %s: unknown align %q", f.Name, f.Align))
+		}
+		if f.Enum != "" {
+			if set, err := cnab.ParseCodeSet(f.Enum); err != nil {
+				errs = append(errs, fmt.Errorf("field %s: %v", f.Name, err))
+			} else {
+				for _, c := range set.Codes {
+					if utf8.RuneCountInString(c.Code) > f.Size {
+						errs = append(errs, fmt.Errorf("field %s: code %q longer than size %d", f.Name, c.Code, f.Size))
+					}
+				}
+			}
+		}
+		if f.Normalize && f.Type != "" && f.Type != "string" {
+			errs = append(errs, fmt.Errorf("field %s: normalize on a non-string field", f.Name))
+		}
+		switch f.Count {
+		case "", cnab.CountRecords, cnab.CountLots:
+		default:
+			errs = append(errs, fmt.Errorf("field %s: unknown count %q", f.Name, f.Count))
+		}
+		if f.Count != "" && f.Sum != "" {
+			errs = append(errs, fmt.Errorf("field %s: count and sum are exclusive", f.Name))
+		}
+		if _, _, err := parseSeq(f.Seq); err != nil {
+			errs = append(errs, fmt.Errorf("field %s: %v", f.Name, err))
+		}
+		if f.Seq != "" && (f.Count != "" || f.Sum != "") {
+			errs = append(errs, fmt.Errorf("field %s: seq, count and sum are exclusive", f.Name))
+		}
+		if (f.Count != "" || f.Sum != "" || f.Seq != "") && !f.numeric() {
+			errs = append(errs, fmt.Errorf("field %s: count, sum or seq on a non-numeric field", f.Name))
+		}
+		if _, err := cnab.ParseRoundingMode(f.Round); err != nil {
+			errs = append(errs, fmt.Errorf("field %s: %v", f.Name, err))
+		}
+	}
+
+	spans := fs.spans()
+	if err := fs.checkSpans(spans); err != nil {
+		errs = append(errs, err)
+	}
+	for i, sp := range spans {
+		if recordLength > 0 && sp.end > recordLength {
+			errs = append(errs, fmt.Errorf("field %s: %w %d", fs[i].Name, cnab.ErrFieldOutOfRange, recordLength))
+		}
+	}
+
+	return errs
+}
+
+// Record returns the record type with the given name, or nil.
+func (l *Layout) Record(name string) *Record {
+	for i := range l.Records {
+		if l.Records[i].Name == name {
+			return &l.Records[i]
+		}
+	}
+	return nil
+}
+
+// Match returns the record type of line, or cnab.ErrUnknownRecord.
+func (l *Layout) Match(line []byte) (*Record, error) {
+	for i := range l.Records {
+		if l.Records[i].matches(line) {
+			return &l.Records[i], nil
+		}
+	}
+	return nil, cnab.ErrUnknownRecord
+}
+
+func (r *Record) matches(line []byte) bool {
+	text := []rune(string(line))
+	for _, m := range r.Match {
+		end := m.Start + utf8.RuneCountInString(m.Value) - 1
+		if m.Start < 1 || end > len(text) || string(text[m.Start-1:end]) != m.Value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package dynamic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+const testLayoutJSON = `{
+  "version": 1,
+  "name": "test-20",
+  "recordLength": 20,
+  "records": [
+    {
+      "name": "header",
+      "match": [{"start": 1, "value": "0"}],
+      "fields": [
+        {"name": "Type", "size": 1},
+        {"name": "Bank", "size": 3, "type": "int"},
+        {"name": "Name", "size": 16}
+      ]
+    },
+    {
+      "name": "detail",
+      "match": [{"start": 1, "value": "1"}],
+      "fields": [
+        {"name": "Type", "size": 1},
+        {"name": "Amount", "start": 11, "size": 10, "type": "decimal", "decimal": 2}
+      ]
+    }
+  ]
+}`
+
+const testLayoutYAML = `
+version: 1
+name: test-20
+recordLength: 20
+records:
+  - name: header
+    match:
+      - {start: 1, value: "0"}
+    fields:
+      - {name: Type, size: 1}
+      - {name: Bank, size: 3, type: int}
+      - {name: Name, size: 16}
+  - name: detail
+    match:
+      - {start: 1, value: "1"}
+    fields:
+      - {name: Type, size: 1}
+      - {name: Amount, start: 11, size: 10, type: decimal, decimal: 2, fill: 0}
+`
+
+func TestLoadLayout(t *testing.T) {
+	for name, doc := range map[string]string{"json": testLayoutJSON, "yaml": testLayoutYAML} {
+		l, err := LoadLayout(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("%s: LoadLayout failed: %v", name, err)
+		}
+
+		if l.Name != "test-20" || l.RecordLength != 20 || len(l.Records) != 2 {
+			t.Fatalf("%s: unexpected layout: %+v", name, l)
+		}
+
+		rec, err := l.Match([]byte("1         0000012345"))
+		if err != nil {
+			t.Fatalf("%s: Match failed: %v", name, err)
+		}
+		if rec.Name != "detail" {
+			t.Fatalf("%s: expected detail record, got %s", name, rec.Name)
+		}
+
+		data, err := Unmarshal([]byte("1         0000012345"), rec.Fields)
+		if err != nil {
+			t.Fatalf("%s: Unmarshal failed: %v", name, err)
+		}
+		if d := data["Amount"].(cnab.Decimal); d.String() != "123.45" {
+			t.Fatalf("%s: expected Amount 123.45, got %s", name, d)
+		}
+
+		if l.Record("header") == nil || l.Record("missing") != nil {
+			t.Fatalf("%s: unexpected Record lookup result", name)
+		}
+		if _, err := l.Match([]byte("9")); !errors.Is(err, cnab.ErrUnknownRecord) {
+			t.Fatalf("%s: expected ErrUnknownRecord, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadLayoutValidation(t *testing.T) {
+	doc := `{
+  "version": 2,
+  "recordLength": 10,
+  "records": [
+    {"name": "a", "fields": [
+      {"name": "X", "size": 6, "type": "money"},
+      {"name": "Y", "start": 5, "size": 8}
+    ]},
+    {"name": "a", "fields": []}
+  ]
+}`
+
+	_, err := LoadLayout(strings.NewReader(doc))
+	if !errors.Is(err, ErrInvalidLayout) {
+		t.Fatalf("expected ErrInvalidLayout, got %v", err)
+	}
+
+	for _, msg := range []string{
+		"unsupported version 2",
+		`duplicate record "a"`,
+		`unknown type "money"`,
+		"overlapping fields",
+		"field exceeds record length",
+	} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected %q in error, got:\n%v", msg, err)
+		}
+	}
+}
+
+func TestLoadLayoutUnknownKey(t *testing.T) {
+	doc := `{"version": 1, "recordLength": 5, "records": [{"name": "a", "fields": [{"name": "X", "sise": 5}]}]}`
+
+	_, err := LoadLayout(strings.NewReader(doc))
+	if !errors.Is(err, ErrInvalidLayout) || !strings.Contains(err.Error(), "sise") {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestLayoutMatchStart(t *testing.T) {
+	// Layouts built in code may skip Validate
+	l := &Layout{RecordLength: 5, Records: []Record{
+		{Name: "bad", Match: []Match{{Start: 0, Value: "1"}}},
+		{Name: "detail", Match: []Match{{Start: 1, Value: "1"}}},
+	}}
+
+	rec, err := l.Match([]byte("1ABCD"))
+	if err != nil || rec.Name != "detail" {
+		t.Fatalf("expected detail, got %v, %v", rec, err)
+	}
+}
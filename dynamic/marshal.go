@@ -1,50 +1,77 @@
 package dynamic
 
 import (
-	"bytes"
 	"fmt"
-	"math"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/HigorGrigorio/cnab"
 )
 
 // Marshal takes a map of data and a list of fields definition, returning a CNAB line.
+// Fields are placed at their Start position (or right after the previous
+// field); positions not covered by any field are filled with spaces.
+// Fields with a Literal always write it, ignoring data. Nil values and nil
+// pointers are written as the field's Blank text, or as fill. Positions
+// and sizes count characters, so the line may hold any UTF-8 text.
 func Marshal(data map[string]interface{}, layout []Field) ([]byte, error) {
-	var buf bytes.Buffer
+	return MarshalNormalized(data, layout, nil)
+}
+
+// MarshalNormalized is Marshal with n normalizing the fields that have
+// Normalize set, e.g. to apply a bank's allow-list. A nil n uses
+// cnab.NewNormalizer's settings.
+func MarshalNormalized(data map[string]interface{}, layout []Field, n *cnab.Normalizer) ([]byte, error) {
+	if n == nil {
+		n = defaultNormalizer
+	}
+	spans := Fields(layout).spans()
+	if err := Fields(layout).checkSpans(spans); err != nil {
+		return nil, err
+	}
 
-	for _, field := range layout {
+	buf := []rune(strings.Repeat(" ", width(spans)))
+
+	for i, field := range layout {
 		val, ok := data[field.Name]
-		if !ok && field.Required {
-			return nil, fmt.Errorf("field %s is required but missing", field.Name)
+		if field.Literal != "" {
+			val, ok = field.Literal, true
+		}
+		val = deref(val)
+		if field.Required && (!ok || isBlank(val)) {
+			return nil, fmt.Errorf("field %s: %w", field.Name, cnab.ErrRequired)
+		}
+		if val == nil && field.Blank != "" {
+			copy(buf[spans[i].start-1:spans[i].end], []rune(field.blank()))
+			continue
 		}
 
 		s, err := formatValue(val, field)
 		if err != nil {
 			return nil, fmt.Errorf("field %s: %w", field.Name, err)
 		}
-
-		if len(s) > field.Size {
-			return nil, fmt.Errorf("field %s: value '%s' too long for size %d", field.Name, s, field.Size)
+		if field.Normalize && field.Literal == "" {
+			s = n.Normalize(s)
 		}
 
-		// Defaults
-		fill := " "
-		if field.Fill != "" {
-			fill = field.Fill
-		} else if field.Type == "int" || field.Type == "float" {
-			fill = "0"
+		if field.Enum != "" && field.Literal == "" {
+			if _, err := field.findCode(s); err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
 		}
 
-		align := "left"
-		if field.Align != "" {
-			align = field.Align
-		} else if field.Type == "int" || field.Type == "float" {
-			align = "right"
+		if utf8.RuneCountInString(s) > field.Size {
+			return nil, fmt.Errorf("field %s: value '%s' too long for size %d", field.Name, s, field.Size)
 		}
 
+		fill := field.fill()
+		align := field.align()
+
 		// Apply padding
-		padding := field.Size - len(s)
+		padding := field.Size - utf8.RuneCountInString(s)
 		if padding > 0 {
 			padStr := strings.Repeat(fill, padding)
 			if align == "right" {
@@ -58,10 +85,44 @@ func Marshal(data map[string]interface{}, layout []Field) ([]byte, error) {
 			}
 		}
 
-		buf.WriteString(s)
+		if utf8.RuneCountInString(s) != field.Size {
+			return nil, fmt.Errorf("field %s: %w", field.Name, cnab.ErrFieldSizeMismatch)
+		}
+
+		copy(buf[spans[i].start-1:], []rune(s))
 	}
 
-	return buf.Bytes(), nil
+	return []byte(string(buf)), nil
+}
+
+// deref returns the value pointed to by v, or nil for nil pointers, so
+// that optional values from typed models can be marshaled directly.
+func deref(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// isBlank reports whether v carries no value: nil, a whitespace-only
+// string or a zero time.
+func isBlank(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(val) == ""
+	case time.Time:
+		return val.IsZero()
+	}
+	return false
 }
 
 func formatValue(v interface{}, f Field) (string, error) {
@@ -85,13 +146,23 @@ func formatValue(v interface{}, f Field) (string, error) {
 		}
 
 		if f.Decimal > 0 {
-			mult := math.Pow(10, float64(f.Decimal))
-			vi := int64(math.Round(vf * mult))
-			return fmt.Sprintf("%d", vi), nil
+			d, err := cnab.NewDecimalFromFloat(vf)
+			if err != nil {
+				return "", err
+			}
+			return formatDecimal(d, f)
 		}
 		// Default float format if decimal not specified? Or error?
 		// Let's assume standard float string
 		return strconv.FormatFloat(vf, 'f', -1, 64), nil
+	case bool:
+		t, fc := f.boolCodes()
+		if val {
+			return t, nil
+		}
+		return fc, nil
+	case cnab.Decimal:
+		return formatDecimal(val, f)
 	case time.Time:
 		format := f.Format
 		if format == "" {
@@ -119,20 +190,26 @@ func formatStringValue(s string, f Field) (string, error) {
 		}
 		return fmt.Sprintf("%d", val), nil
 
-	case "float":
+	case "float", "decimal":
 		if trimmed == "" {
 			return "", nil
 		}
-		parsed, err := strconv.ParseFloat(trimmed, 64)
+		parsed, err := cnab.ParseDecimal(trimmed)
 		if err != nil {
-			return "", fmt.Errorf("cannot convert string '%s' to float: %w", s, err)
+			return "", fmt.Errorf("cannot convert string '%s' to %s: %w", s, f.Type, err)
 		}
-		if f.Decimal > 0 {
-			mult := math.Pow(10, float64(f.Decimal))
-			val := int64(math.Round(parsed * mult))
-			return fmt.Sprintf("%d", val), nil
+		return formatDecimal(parsed, f)
+
+	case "bool":
+		t, fc := f.boolCodes()
+		if trimmed == "" || trimmed == t || trimmed == fc {
+			return trimmed, nil
+		}
+		val, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return "", fmt.Errorf("cannot convert string '%s' to bool: %w", s, cnab.ErrInvalidBool)
 		}
-		return strconv.FormatFloat(parsed, 'f', -1, 64), nil
+		return formatValue(val, f)
 
 	case "date":
 		if trimmed == "" {
@@ -151,3 +228,16 @@ func formatStringValue(s string, f Field) (string, error) {
 
 	return s, nil
 }
+
+// formatDecimal renders d as an implicit-decimal integer with f.Decimal
+// digits, or in plain notation when the field declares no decimals.
+func formatDecimal(d cnab.Decimal, f Field) (string, error) {
+	if f.Decimal == 0 {
+		return d.String(), nil
+	}
+	mode, err := cnab.ParseRoundingMode(f.Round)
+	if err != nil {
+		return "", err
+	}
+	return d.Rescale(f.Decimal, mode).Unscaled().String(), nil
+}
@@ -1,9 +1,12 @@
 package dynamic
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/HigorGrigorio/cnab"
 )
 
 func TestMarshal(t *testing.T) {
@@ -140,3 +143,125 @@ func TestMarshalStringDateError(t *testing.T) {
 		t.Fatalf("unexpected error message: %v", err)
 	}
 }
+
+func TestMarshalRequired(t *testing.T) {
+	layout := []Field{
+		{Name: "Name", Size: 5, Required: true},
+		{Name: "Note", Size: 3},
+	}
+
+	for _, data := range []map[string]interface{}{
+		{},
+		{"Name": nil},
+		{"Name": "   "},
+	} {
+		_, err := Marshal(data, layout)
+		if !errors.Is(err, cnab.ErrRequired) {
+			t.Fatalf("expected ErrRequired for %v, got %v", data, err)
+		}
+	}
+
+	res, err := Marshal(map[string]interface{}{"Name": "JOHN"}, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(res) != "JOHN    " {
+		t.Errorf("Expected 'JOHN    ', got '%s'", string(res))
+	}
+}
+
+func TestMarshalDecimal(t *testing.T) {
+	layout := []Field{
+		{Name: "Total", Size: 10, Type: "decimal", Decimal: 2},
+		{Name: "Rate", Size: 5, Type: "decimal", Decimal: 2, Round: "down"},
+		{Name: "Float", Size: 5, Type: "float", Decimal: 2},
+	}
+
+	total, _ := cnab.ParseDecimal("98765432.10")
+	data := map[string]interface{}{
+		"Total": total,
+		"Rate":  "1.239",
+		"Float": 1.005,
+	}
+
+	expected := "98765432100012300101"
+	res, err := Marshal(data, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	if string(res) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, string(res))
+	}
+}
+
+func TestMarshalStartPositions(t *testing.T) {
+	// Copied from a bank manual: columns listed out of order, with a gap at 4-5.
+	layout := []Field{
+		{Name: "Name", Start: 6, Size: 5},
+		{Name: "Code", Start: 1, Size: 3, Type: "int"},
+		{Name: "Seq", Size: 2, Type: "int"}, // follows the furthest field: 11-12
+	}
+
+	data := map[string]interface{}{"Code": 7, "Name": "ABC", "Seq": 1}
+
+	expected := "007  ABC  01"
+	res, err := Marshal(data, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(res) != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, string(res))
+	}
+
+	out, err := Unmarshal(res, layout)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out["Name"] != "ABC" || out["Code"] != int64(7) || out["Seq"] != int64(1) {
+		t.Errorf("unexpected values: %#v", out)
+	}
+}
+
+func TestMarshalOverlap(t *testing.T) {
+	layout := []Field{
+		{Name: "A", Start: 1, Size: 3},
+		{Name: "B", Start: 3, Size: 2},
+	}
+
+	_, err := Marshal(map[string]interface{}{"A": "AAA", "B": "BB"}, layout)
+	if !errors.Is(err, cnab.ErrFieldOverlap) {
+		t.Fatalf("expected ErrFieldOverlap, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "field B") {
+		t.Fatalf("expected overlapping field name in error, got %v", err)
+	}
+}
+
+func TestMarshalNormalize(t *testing.T) {
+	layout := []Field{
+		{Name: "Name", Size: 10, Normalize: true},
+		{Name: "Raw", Size: 4},
+	}
+
+	line, err := Marshal(map[string]interface{}{"Name": "José Ação", "Raw": "ab"}, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(line) != "JOSE ACAO ab  " {
+		t.Fatalf("unexpected line: %q", line)
+	}
+
+	strict := &cnab.Normalizer{Upper: true, Allowed: "ABCDEFGHIJKLMNOPQRSTUVWXYZ", Replacement: '.'}
+	line, err = MarshalNormalized(map[string]interface{}{"Name": "José Ação", "Raw": "ab"}, layout, strict)
+	if err != nil {
+		t.Fatalf("MarshalNormalized failed: %v", err)
+	}
+	if string(line) != "JOS..A..O ab  " {
+		t.Fatalf("unexpected line: %q", line)
+	}
+
+	if errs := (Fields{{Name: "N", Size: 2, Type: "int", Normalize: true}}).validate(0); len(errs) == 0 {
+		t.Fatal("expected normalize on an int field to be rejected")
+	}
+}
@@ -0,0 +1,171 @@
+package dynamic
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+var (
+	marshalerType = reflect.TypeOf((*cnab.Marshaler)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+	decimalType   = reflect.TypeOf(cnab.Decimal{})
+)
+
+// FromStruct derives the layout of a tagged struct (or pointer to one), so
+// that a single definition serves both typed and dynamic code. Fields get
+// their resolved Start; Fill, Align and Round are set only when they differ
+// from the dynamic defaults for the field type. Custom Marshaler types map
+// to "string" and pointer fields are Optional.
+func FromStruct(v interface{}) (Fields, error) {
+	specs, err := cnab.Describe(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(Fields, len(specs))
+	for i, s := range specs {
+		f := Field{
+			Name:      s.Name,
+			Size:      s.Size,
+			Start:     s.Start,
+			Required:  s.Required,
+			Type:      typeOf(s.Type),
+			Format:    s.Format,
+			Decimal:   s.Decimal,
+			Literal:   s.Literal,
+			True:      s.True,
+			False:     s.False,
+			Enum:      s.Enum,
+			Optional:  s.Type.Kind() == reflect.Ptr,
+			Blank:     s.Blank,
+			Normalize: s.Normalize == "on",
+			Count:     s.Count,
+			Sum:       s.Sum,
+			Seq:       seqTag(s.Seq, s.SeqStart),
+		}
+		if fill := string(s.Fill); fill != f.fill() {
+			f.Fill = fill
+		}
+		if s.Align != f.align() {
+			f.Align = s.Align
+		}
+		if s.Rounding != cnab.RoundHalfUp {
+			f.Round = s.Rounding.String()
+		}
+		fields[i] = f
+	}
+	return fields, nil
+}
+
+// typeOf returns the dynamic type name matching how cnab encodes t.
+// Pointers have the type of their element.
+func typeOf(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Implements(marshalerType), reflect.PointerTo(t).Implements(marshalerType):
+		return ""
+	case t == decimalType:
+		return "decimal"
+	case t == timeType:
+		return "date"
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Bool:
+		return "bool"
+	}
+	return ""
+}
+
+// normalizeTag returns the cnab.FieldSpec Normalize value for on.
+func normalizeTag(on bool) string {
+	if on {
+		return "on"
+	}
+	return ""
+}
+
+// seqTag returns the Field.Seq value for a numbering of kind from start.
+func seqTag(kind string, start int) string {
+	if kind == "" || start == 1 {
+		return kind
+	}
+	return kind + "," + strconv.Itoa(start)
+}
+
+// parseSeq splits a Field.Seq value into its kind and first number.
+func parseSeq(s string) (string, int, error) {
+	if s == "" {
+		return "", 0, nil
+	}
+	kind, start, ok := strings.Cut(s, ",")
+	if kind != cnab.SeqFile && kind != cnab.SeqLot {
+		return "", 0, fmt.Errorf("%w: unknown seq %q", cnab.ErrInvalidTag, s)
+	}
+	if !ok {
+		return kind, 1, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(start))
+	if err != nil || n < 0 {
+		return "", 0, fmt.Errorf("%w: invalid seq start %q", cnab.ErrInvalidTag, s)
+	}
+	return kind, n, nil
+}
+
+// Tag returns the `cnab` struct tag equivalent to f, making the type
+// defaults of dynamic layouts (zero fill and right alignment for numbers)
+// explicit. Fields without Start follow the previous field, as in structs.
+func (f Field) Tag() (string, error) {
+	rounding, err := cnab.ParseRoundingMode(f.Round)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", f.Name, err)
+	}
+
+	seq, seqStart, err := parseSeq(f.Seq)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", f.Name, err)
+	}
+
+	fill := []rune(f.fill())
+	if len(fill) != 1 {
+		return "", fmt.Errorf("field %s: %w: fill must be a single character", f.Name, cnab.ErrInvalidTag)
+	}
+
+	tag, err := cnab.FieldSpec{
+		Start:     f.Start,
+		Size:      f.Size,
+		Fill:      fill[0],
+		Align:     f.align(),
+		Format:    f.Format,
+		Decimal:   f.Decimal,
+		Literal:   f.Literal,
+		Required:  f.Required,
+		Rounding:  rounding,
+		True:      f.True,
+		False:     f.False,
+		Enum:      f.Enum,
+		Blank:     f.Blank,
+		Normalize: normalizeTag(f.Normalize),
+		Count:     f.Count,
+		Sum:       f.Sum,
+		Seq:       seq,
+		SeqStart:  seqStart,
+	}.Tag()
+	if err != nil {
+		return "", fmt.Errorf("field %s: %w", f.Name, err)
+	}
+	return tag, nil
+}
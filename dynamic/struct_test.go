@@ -0,0 +1,148 @@
+package dynamic
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+type structRecord struct {
+	Kind   string       `cnab:"literal:1"`
+	Bank   int          `cnab:"size:3;fill:0;align:right"`
+	Seq    int          `cnab:"size:4"`
+	Name   string       `cnab:"start:10;size:10;required"`
+	Date   time.Time    `cnab:"size:8"`
+	Amount cnab.Decimal `cnab:"size:8;fill:0;align:right;decimal:2;round:down"`
+	Rate   float64      `cnab:"size:5;fill:0;align:right;decimal:3"`
+}
+
+func TestFromStruct(t *testing.T) {
+	fields, err := FromStruct(&structRecord{})
+	if err != nil {
+		t.Fatalf("FromStruct failed: %v", err)
+	}
+
+	expected := Fields{
+		{Name: "Kind", Size: 1, Start: 1, Literal: "1"},
+		{Name: "Bank", Size: 3, Start: 2, Type: "int"},
+		{Name: "Seq", Size: 4, Start: 5, Type: "int", Fill: " ", Align: "left"},
+		{Name: "Name", Size: 10, Start: 10, Required: true},
+		{Name: "Date", Size: 8, Start: 20, Type: "date"},
+		{Name: "Amount", Size: 8, Start: 28, Type: "decimal", Decimal: 2, Round: "down"},
+		{Name: "Rate", Size: 5, Start: 36, Type: "float", Decimal: 3},
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("unexpected layout:\nGot:  %+v\nWant: %+v", fields, expected)
+	}
+
+	// Both representations produce the same line.
+	rec := structRecord{
+		Bank:   33,
+		Seq:    7,
+		Name:   "ACME",
+		Date:   time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Amount: cnab.NewDecimal(12345, 2),
+		Rate:   1.5,
+	}
+	want, err := cnab.Marshal(rec)
+	if err != nil {
+		t.Fatalf("cnab.Marshal failed: %v", err)
+	}
+	got, err := Marshal(map[string]interface{}{
+		"Bank": rec.Bank, "Seq": rec.Seq, "Name": rec.Name,
+		"Date": rec.Date, "Amount": rec.Amount, "Rate": rec.Rate,
+	}, fields)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Fatalf("line mismatch:\nGot:  '%s'\nWant: '%s'", got, want)
+	}
+}
+
+func TestFieldTagRoundTrip(t *testing.T) {
+	for _, v := range []interface{}{structRecord{}, structTrailer{}} {
+		fields, err := FromStruct(v)
+		if err != nil {
+			t.Fatalf("FromStruct failed: %v", err)
+		}
+		if again := roundTrip(t, fields); !reflect.DeepEqual(fields, again) {
+			t.Fatalf("round trip mismatch:\nGot:  %+v\nWant: %+v", again, fields)
+		}
+	}
+}
+
+// roundTrip derives the layout of a struct built from the tags of fields.
+func roundTrip(t *testing.T, fields Fields) Fields {
+	t.Helper()
+	goTypes := map[string]reflect.Type{
+		"":        reflect.TypeOf(""),
+		"int":     reflect.TypeOf(0),
+		"float":   reflect.TypeOf(0.0),
+		"decimal": reflect.TypeOf(cnab.Decimal{}),
+		"date":    reflect.TypeOf(time.Time{}),
+	}
+
+	sfs := make([]reflect.StructField, len(fields))
+	for i, f := range fields {
+		tag, err := f.Tag()
+		if err != nil {
+			t.Fatalf("Tag failed: %v", err)
+		}
+		sfs[i] = reflect.StructField{Name: f.Name, Type: goTypes[f.Type], Tag: reflect.StructTag(`cnab:"` + tag + `"`)}
+	}
+
+	again, err := FromStruct(reflect.New(reflect.StructOf(sfs)).Interface())
+	if err != nil {
+		t.Fatalf("FromStruct of generated struct failed: %v", err)
+	}
+	return again
+}
+
+type structTrailer struct {
+	Kind    string       `cnab:"size:1;literal:9"`
+	Records int          `cnab:"size:6;count:records"`
+	Total   cnab.Decimal `cnab:"size:10;decimal:2;sum:Detail.Amount"`
+	Number  int          `cnab:"size:6;seq:file,0"`
+	Lot     int          `cnab:"size:4;seq:lot"`
+}
+
+func TestFromStructAggregates(t *testing.T) {
+	fields, err := FromStruct(structTrailer{})
+	if err != nil {
+		t.Fatalf("FromStruct failed: %v", err)
+	}
+	if fields[1].Count != cnab.CountRecords || fields[2].Sum != "Detail.Amount" {
+		t.Fatalf("aggregates lost: %+v", fields)
+	}
+	if fields[3].Seq != "file,0" || fields[4].Seq != cnab.SeqLot {
+		t.Fatalf("numbering lost: %+v", fields[3:])
+	}
+
+	if errs := (Fields{{Name: "N", Size: 2, Count: "rows", Type: "int"}}).validate(0); len(errs) == 0 {
+		t.Error("expected an unknown count to be rejected")
+	}
+	if errs := (Fields{{Name: "N", Size: 2, Sum: "Amount"}}).validate(0); len(errs) == 0 {
+		t.Error("expected sum on a string field to be rejected")
+	}
+	if errs := (Fields{{Name: "N", Size: 2, Seq: "page", Type: "int"}}).validate(0); len(errs) == 0 {
+		t.Error("expected an unknown seq to be rejected")
+	}
+}
+
+func TestMarshalLiteral(t *testing.T) {
+	layout := []Field{
+		{Name: "Kind", Size: 1, Literal: "1"},
+		{Name: "Name", Size: 4},
+	}
+
+	line, err := Marshal(map[string]interface{}{"Kind": "X", "Name": "AB"}, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(line) != "1AB  " {
+		t.Fatalf("unexpected line: '%s'", line)
+	}
+}
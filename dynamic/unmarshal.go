@@ -0,0 +1,135 @@
+package dynamic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+// Unmarshal parses a CNAB line using a list of field definitions, returning
+// the typed values keyed by field name: int64 for "int", float64 for "float",
+// cnab.Decimal for "decimal", time.Time for "date", bool for "bool" and
+// string otherwise. Bool fields accept only their true and false codes, and
+// Optional fields holding blank text yield nil.
+// Fields are read from their Start position, so overlapping fields (such as
+// a composite code and its parts) may share characters. Positions count
+// characters of the UTF-8 line.
+func Unmarshal(line []byte, layout []Field) (map[string]interface{}, error) {
+	s := []rune(string(line))
+	data := make(map[string]interface{}, len(layout))
+
+	spans := Fields(layout).spans()
+	for i, field := range layout {
+		start, end := spans[i].start, spans[i].end
+		if start < 1 || field.Size <= 0 {
+			return nil, fmt.Errorf("field %s: %w: invalid start/size", field.Name, cnab.ErrInvalidTag)
+		}
+		if end > len(s) {
+			return nil, fmt.Errorf("field %s: %w", field.Name, cnab.ErrLineTooShort)
+		}
+
+		raw := string(s[start-1 : end])
+		if field.Optional && field.isBlankText(raw) {
+			if field.Required {
+				return nil, fmt.Errorf("field %s: %w", field.Name, cnab.ErrRequired)
+			}
+			data[field.Name] = nil
+			continue
+		}
+
+		text := trimValue(raw, field)
+		if field.Required && text == "" {
+			return nil, fmt.Errorf("field %s: %w", field.Name, cnab.ErrRequired)
+		}
+
+		if field.Enum != "" {
+			if _, err := field.findCode(text); err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+		}
+
+		val, err := parseValue(text, field)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: cannot parse '%s': %w", field.Name, raw, err)
+		}
+		data[field.Name] = val
+	}
+
+	return data, nil
+}
+
+// trimValue removes the padding added by Marshal. Numbers and dates are
+// also stripped of surrounding spaces.
+func trimValue(s string, f Field) string {
+	if f.align() == "right" {
+		s = strings.TrimLeft(s, f.fill())
+	} else {
+		s = strings.TrimRight(s, f.fill())
+	}
+	if f.Type != "" && f.Type != "string" {
+		s = strings.TrimSpace(s)
+	}
+	return s
+}
+
+func parseValue(s string, f Field) (interface{}, error) {
+	switch f.Type {
+	case "int":
+		if s == "" {
+			return int64(0), nil
+		}
+		val, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, cnab.ErrInvalidNumberFormat
+		}
+		return val, nil
+
+	case "float", "decimal":
+		d, err := parseDecimal(s, f)
+		if err != nil {
+			return nil, err
+		}
+		if f.Type == "float" {
+			return d.Float64(), nil
+		}
+		return d, nil
+
+	case "bool":
+		t, fc := f.boolCodes()
+		switch s {
+		case t:
+			return true, nil
+		case fc:
+			return false, nil
+		}
+		return nil, cnab.ErrInvalidBool
+
+	case "date":
+		if s == "" {
+			return time.Time{}, nil
+		}
+		format := f.Format
+		if format == "" {
+			format = "20060102"
+		}
+		val, err := time.Parse(format, s)
+		if err != nil {
+			return nil, cnab.ErrInvalidDateFormat
+		}
+		return val, nil
+	}
+
+	return s, nil
+}
+
+// parseDecimal reads an implicit-decimal number with f.Decimal digits.
+func parseDecimal(s string, f Field) (cnab.Decimal, error) {
+	if strings.Trim(s, "-+") == "" {
+		// Zero fill strips every digit of a zero amount
+		return cnab.NewDecimal(0, f.Decimal), nil
+	}
+	return cnab.ParseImplicitDecimal(s, f.Decimal)
+}
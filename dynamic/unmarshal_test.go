@@ -0,0 +1,218 @@
+package dynamic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+func TestUnmarshal(t *testing.T) {
+	layout := []Field{
+		{Name: "Code", Size: 3, Type: "int"},
+		{Name: "Name", Size: 10},
+		{Name: "Date", Size: 8, Type: "date", Format: "20060102"},
+		{Name: "Amount", Size: 10, Type: "float", Decimal: 2},
+		{Name: "Total", Size: 10, Type: "decimal", Decimal: 2},
+		{Name: "Empty", Size: 8, Type: "date"},
+	}
+
+	line := "001TEST      2023102500000123450000000000        "
+	data, err := Unmarshal([]byte(line), layout)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if data["Code"] != int64(1) {
+		t.Errorf("Expected Code 1, got %#v", data["Code"])
+	}
+	if data["Name"] != "TEST" {
+		t.Errorf("Expected Name 'TEST', got %#v", data["Name"])
+	}
+	if d, ok := data["Date"].(time.Time); !ok || !d.Equal(time.Date(2023, 10, 25, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Date 2023-10-25, got %#v", data["Date"])
+	}
+	if data["Amount"] != 123.45 {
+		t.Errorf("Expected Amount 123.45, got %#v", data["Amount"])
+	}
+	if d, ok := data["Total"].(cnab.Decimal); !ok || d.String() != "0.00" {
+		t.Errorf("Expected Total 0.00, got %#v", data["Total"])
+	}
+	if d, ok := data["Empty"].(time.Time); !ok || !d.IsZero() {
+		t.Errorf("Expected zero Empty date, got %#v", data["Empty"])
+	}
+}
+
+func TestUnmarshalRoundTrip(t *testing.T) {
+	layout := []Field{
+		{Name: "ID", Size: 5, Type: "int"},
+		{Name: "Desc", Size: 10, Fill: "*", Align: "right"},
+		{Name: "Value", Size: 8, Type: "decimal", Decimal: 3},
+	}
+
+	value, _ := cnab.ParseDecimal("-12.345")
+	in := map[string]interface{}{"ID": 50, "Desc": "ITEM", "Value": value}
+
+	line, err := Marshal(in, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	out, err := Unmarshal(line, layout)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if out["ID"] != int64(50) || out["Desc"] != "ITEM" {
+		t.Errorf("unexpected values: %#v", out)
+	}
+	if d := out["Value"].(cnab.Decimal); d.Cmp(value) != 0 {
+		t.Errorf("Expected Value -12.345, got %s", d)
+	}
+}
+
+func TestUnmarshalByCharacter(t *testing.T) {
+	layout := []Field{
+		{Name: "Name", Size: 6},
+		{Name: "City", Size: 4},
+		{Name: "ID", Size: 2, Type: "int"},
+	}
+
+	line, err := Marshal(map[string]interface{}{"Name": "João", "City": "Sé", "ID": 7}, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(line) != "João  Sé  07" {
+		t.Fatalf("unexpected line: %q", line)
+	}
+
+	out, err := Unmarshal(line, layout)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out["Name"] != "João" || out["City"] != "Sé" || out["ID"] != int64(7) {
+		t.Errorf("unexpected values: %#v", out)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	layout := []Field{
+		{Name: "Code", Size: 3, Type: "int", Required: true},
+		{Name: "Date", Size: 8, Type: "date"},
+	}
+
+	tests := []struct {
+		line string
+		want error
+	}{
+		{"001", cnab.ErrLineTooShort},
+		{"0A120231025", cnab.ErrInvalidNumberFormat},
+		{"00120231325", cnab.ErrInvalidDateFormat},
+		{"00020231025", cnab.ErrRequired},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal([]byte(tt.line), layout)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("line %q: expected %v, got %v", tt.line, tt.want, err)
+		}
+	}
+
+	for _, f := range []Field{{Name: "A", Size: -2}, {Name: "A", Size: 0}, {Name: "A", Start: -1, Size: 1}} {
+		if _, err := Unmarshal([]byte("00120231025"), []Field{f}); !errors.Is(err, cnab.ErrInvalidTag) {
+			t.Errorf("%+v: expected ErrInvalidTag, got %v", f, err)
+		}
+	}
+}
+
+func TestBoolRoundTrip(t *testing.T) {
+	layout := []Field{
+		{Name: "Active", Size: 1, Type: "bool"},
+		{Name: "Status", Size: 1, Type: "bool", True: "A", False: "I"},
+		{Name: "Flag", Size: 1, Type: "bool", True: "1", False: "2"},
+	}
+
+	line, err := Marshal(map[string]interface{}{"Active": true, "Status": "false", "Flag": "1"}, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(line) != "SI1" {
+		t.Fatalf("unexpected line: '%s'", line)
+	}
+
+	out, err := Unmarshal(line, layout)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out["Active"] != true || out["Status"] != false || out["Flag"] != true {
+		t.Fatalf("unexpected values: %#v", out)
+	}
+
+	if _, err := Unmarshal([]byte("SX1"), layout); !errors.Is(err, cnab.ErrInvalidBool) {
+		t.Fatalf("expected ErrInvalidBool, got %v", err)
+	}
+	if _, err := Marshal(map[string]interface{}{"Status": "maybe"}, layout); !errors.Is(err, cnab.ErrInvalidBool) {
+		t.Fatalf("expected ErrInvalidBool, got %v", err)
+	}
+}
+
+func TestEnum(t *testing.T) {
+	layout := []Field{
+		{Name: "Carteira", Size: 2, Enum: "01=Simples|02=Vinculada"},
+		{Name: "Ocorrencia", Size: 2, Type: "int", Enum: "02=Entrada|06=Liquidação"},
+	}
+
+	line, err := Marshal(map[string]interface{}{"Carteira": "01", "Ocorrencia": 6}, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	out, err := Unmarshal(line, layout)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if label, err := layout[1].Label(out["Ocorrencia"]); err != nil || label != "Liquidação" {
+		t.Fatalf("unexpected label %q, %v", label, err)
+	}
+
+	if _, err := Marshal(map[string]interface{}{"Carteira": "03"}, layout); !errors.Is(err, cnab.ErrInvalidCode) {
+		t.Fatalf("expected ErrInvalidCode, got %v", err)
+	}
+	_, err = Unmarshal([]byte("0109"), layout)
+	if !errors.Is(err, cnab.ErrInvalidCode) || !strings.Contains(err.Error(), "Ocorrencia") {
+		t.Fatalf("expected ErrInvalidCode on Ocorrencia, got %v", err)
+	}
+}
+
+func TestOptional(t *testing.T) {
+	layout := []Field{
+		{Name: "Discount", Size: 8, Type: "date", Optional: true, Blank: "0"},
+		{Name: "Amount", Size: 6, Type: "decimal", Decimal: 2, Optional: true},
+	}
+
+	var missing *time.Time
+	line, err := Marshal(map[string]interface{}{"Discount": missing}, layout)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(line) != "00000000000000" {
+		t.Fatalf("unexpected line: '%s'", line)
+	}
+
+	out, err := Unmarshal(line, layout)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out["Discount"] != nil || out["Amount"] != nil {
+		t.Fatalf("expected nil values, got %#v", out)
+	}
+
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	line, _ = Marshal(map[string]interface{}{"Discount": &date, "Amount": "1.5"}, layout)
+	out, err = Unmarshal(line, layout)
+	if err != nil || !out["Discount"].(time.Time).Equal(date) || out["Amount"].(cnab.Decimal).String() != "1.50" {
+		t.Fatalf("unexpected values from '%s': %#v, %v", line, out, err)
+	}
+}
@@ -1,16 +1,21 @@
 package cnab
 
 import (
-	"bytes"
 	"fmt"
-	"math"
 	"reflect"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func encode(v interface{}) ([]byte, error) {
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	timeType        = reflect.TypeOf(time.Time{})
+	decimalType     = reflect.TypeOf(Decimal{})
+)
+
+func encode(v interface{}, norm *Normalizer) ([]byte, error) {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() == reflect.Ptr {
 		rv = rv.Elem()
@@ -20,162 +25,279 @@ func encode(v interface{}) ([]byte, error) {
 		return nil, ErrInvalidStruct
 	}
 
-	type segment struct {
-		start int // 1-based
-		end   int
-		text  string
+	c, err := codecFor(rv.Type())
+	if err != nil {
+		return nil, err
 	}
+	return c.encode(nil, rv, norm)
+}
 
-	var segments []segment
-	nextPos := 0
-	maxEnd := 0
-
-	t := rv.Type()
+// encode appends the encoding of the struct value rv to dst, normalizing
+// text fields with norm when it is not nil.
+// Positions not covered by any field are filled with spaces.
+func (c *codec) encode(dst []byte, rv reflect.Value, norm *Normalizer) ([]byte, error) {
+	if c.overlap != nil {
+		return nil, c.overlapError()
+	}
 
-	for i := 0; i < rv.NumField(); i++ {
-		field := t.Field(i)
-		tagValue := field.Tag.Get("cnab")
-		if tagValue == "" {
-			continue
-		}
+	if c.needAddr && !rv.CanAddr() {
+		// Pointer-receiver Marshalers need an addressable copy
+		addr := reflect.New(rv.Type()).Elem()
+		addr.Set(rv)
+		rv = addr
+	}
 
-		tag, err := parseTag(tagValue)
-		if err != nil {
-			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+	for _, r := range c.repeats {
+		if v, ok := r.value(rv); ok && v.Len() > r.tag.repeat {
+			err := fmt.Errorf("%w: %d items, at most %d", ErrTooManyOccurrences, v.Len(), r.tag.repeat)
+			return nil, r.fieldError(err, "")
 		}
+	}
 
-		val := rv.Field(i)
+	base := len(dst)
+	for i := 0; i < c.width; i++ {
+		dst = append(dst, ' ')
+	}
+	buf := dst[base:]
+	var wide []rune // the record by character, once some text is not ASCII
 
+	for _, f := range c.fields {
 		var s string
-
-		if tag.literalValue != "" {
-			s = tag.literalValue
-		} else {
-			var formatErr error
-			s, formatErr = formatValue(val, tag)
-			if formatErr != nil {
-				return nil, fmt.Errorf("field %s: %w", field.Name, formatErr)
-			}
-		}
-
-		if len(s) > tag.size {
-			return nil, fmt.Errorf("field %s: value '%s' too long for size %d", field.Name, s, tag.size)
-		}
-
-		// Apply padding
-		padding := tag.size - len(s)
-		if padding > 0 {
-			padStr := strings.Repeat(string(tag.fill), padding)
-			if tag.align == "right" {
-				if tag.fill == '0' && strings.HasPrefix(s, "-") {
-					// Handle negative number with zero padding: -0001
-					s = "-" + padStr + s[1:]
-				} else {
-					s = padStr + s
-				}
-			} else {
-				s = s + padStr
+		if val, ok := f.value(rv); ok {
+			var fe *FieldError
+			if s, fe = f.encode(val, norm); fe != nil {
+				return nil, fe
 			}
+		} else {
+			// Missing occurrences of repeated fields are left blank
+			s = f.absent()
 		}
 
-		start := tag.start
-		if start == 0 {
-			start = nextPos + 1
+		if wide == nil && len(s) != f.end-f.start+1 {
+			wide = []rune(string(buf))
 		}
-		end := start + tag.size - 1
-		if tag.end > 0 {
-			end = tag.end
+		if wide != nil {
+			copy(wide[f.start-1:f.end], []rune(s))
+		} else {
+			copy(buf[f.start-1:f.end], s)
 		}
+	}
 
-		if start <= 0 || end < start {
-			return nil, fmt.Errorf("field %s: invalid start/end interval", field.Name)
-		}
+	if wide != nil {
+		dst = append(dst[:base], string(wide)...)
+	}
+	return dst, nil
+}
 
-		segments = append(segments, segment{start: start, end: end, text: s})
-		if end > maxEnd {
-			maxEnd = end
-		}
-		if end > nextPos {
-			nextPos = end
-		}
+// absent returns the text of a missing occurrence: its literal or blank text.
+func (f *codecField) absent() string {
+	if f.tag.literalValue != "" {
+		return pad(f.tag.literalValue, &f.tag)
 	}
+	return blankText(&f.tag)
+}
 
-	if maxEnd == 0 {
-		return []byte{}, nil
+// normalizer returns the Normalizer applied to f: the Encoder's, unless
+// the tag forces the default one or disables it.
+func (f *codecField) normalizer(norm *Normalizer) *Normalizer {
+	switch {
+	case !f.text || f.tag.normalize == "-":
+		return nil
+	case f.tag.normalize == "on" && norm == nil:
+		return defaultNormalizer
 	}
+	return norm
+}
 
-	buf := bytes.Repeat([]byte(" "), maxEnd)
-	used := make([]bool, maxEnd)
+// encode formats and pads the value of a single field.
+func (f *codecField) encode(val reflect.Value, norm *Normalizer) (string, *FieldError) {
+	tag := &f.tag
 
-	for _, seg := range segments {
-		if len(seg.text) != (seg.end - seg.start + 1) {
-			return nil, fmt.Errorf("segment size mismatch at start %d", seg.start)
+	var s string
+	if tag.literalValue != "" {
+		s = tag.literalValue
+	} else if tag.required && val.Kind() != reflect.Bool && isZero(val) {
+		// false is a value; only a nil *bool is missing
+		return "", f.fieldError(ErrRequired, "")
+	} else if f.blank != "" && val.IsNil() {
+		return f.blank, nil
+	} else {
+		var err error
+		s, err = f.format(val, tag)
+		if err != nil {
+			return "", f.fieldError(err, "")
+		}
+		if n := f.normalizer(norm); n != nil {
+			s = n.Normalize(s)
 		}
-		for j := 0; j < len(seg.text); j++ {
-			idx := seg.start - 1 + j
-			if used[idx] {
-				return nil, fmt.Errorf("overlapping fields at position %d", seg.start+j)
+		if f.codes != nil {
+			if err := f.checkCode(s); err != nil {
+				return "", f.fieldError(err, s)
 			}
-			used[idx] = true
-			buf[idx] = seg.text[j]
 		}
 	}
 
-	return buf, nil
+	if runeCount(s) > tag.size {
+		return "", f.fieldError(fmt.Errorf("%w: value too long for size %d", ErrFieldSizeMismatch, tag.size), s)
+	}
+
+	s = pad(s, tag)
+	if runeCount(s) != f.end-f.start+1 {
+		return "", f.fieldError(ErrFieldSizeMismatch, s)
+	}
+	return s, nil
 }
 
-func formatValue(v reflect.Value, tag fieldTag) (string, error) {
-	// Check for Marshaler interface
-	if v.CanInterface() {
-		if m, ok := v.Interface().(Marshaler); ok {
-			b, err := m.MarshalCNAB()
-			if err != nil {
-				return "", err
-			}
-			return string(b), nil
-		}
+// pad fills s up to the field size according to the tag's fill and alignment.
+func pad(s string, tag *fieldTag) string {
+	padding := tag.size - runeCount(s)
+	if padding <= 0 {
+		return s
 	}
 
-	// Also check if addressable and pointer implements Marshaler
-	// (e.g. func (t *Type) MarshalCNAB...)
-	if v.CanAddr() {
-		if m, ok := v.Addr().Interface().(Marshaler); ok {
-			b, err := m.MarshalCNAB()
-			if err != nil {
-				return "", err
-			}
-			return string(b), nil
+	padStr := strings.Repeat(string(tag.fill), padding)
+	if tag.align == "right" {
+		if tag.fill == '0' && strings.HasPrefix(s, "-") {
+			// Handle negative number with zero padding: -0001
+			return "-" + padStr + s[1:]
 		}
+		return padStr + s
+	}
+	return s + padStr
+}
+
+// formatFunc converts a field value into its unpadded CNAB text.
+type formatFunc func(v reflect.Value, tag *fieldTag) (string, error)
+
+// formatterFor returns the formatter for values of type t,
+// or nil when t cannot be encoded.
+func formatterFor(t reflect.Type) formatFunc {
+	if t.Kind() == reflect.Ptr {
+		return formatPointer(formatterFor(t.Elem()))
+	}
+
+	switch {
+	case t.Implements(marshalerType):
+		return formatMarshaler
+	case reflect.PointerTo(t).Implements(marshalerType):
+		return formatAddrMarshaler
+	case t == decimalType:
+		return formatDecimalValue
+	case t == timeType:
+		return formatTime
 	}
 
-	switch v.Kind() {
+	switch t.Kind() {
 	case reflect.String:
-		return v.String(), nil
+		return formatString
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return strconv.FormatInt(v.Int(), 10), nil
+		return formatInt
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return strconv.FormatUint(v.Uint(), 10), nil
+		return formatUint
 	case reflect.Float32, reflect.Float64:
-		if tag.decimal > 0 {
-			// Multiply by 10^decimal and round to int
-			f := v.Float()
-			mult := math.Pow(10, float64(tag.decimal))
-			val := int64(math.Round(f * mult))
-			return strconv.FormatInt(val, 10), nil
+		return formatFloat
+	case reflect.Bool:
+		return formatBool
+	}
+	return nil
+}
+
+// formatPointer formats the value pointed to with elem. Nil pointers
+// yield an empty text.
+func formatPointer(elem formatFunc) formatFunc {
+	if elem == nil {
+		return nil
+	}
+	return func(v reflect.Value, tag *fieldTag) (string, error) {
+		if v.IsNil() {
+			return "", nil
 		}
-		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
-	case reflect.Struct:
-		if v.Type() == reflect.TypeOf(time.Time{}) {
-			t := v.Interface().(time.Time)
-			if t.IsZero() {
-				return "", nil
-			}
-			f := tag.format
-			if f == "" {
-				f = "20060102" // Default CNAB date format
-			}
-			return t.Format(f), nil
+		return elem(v.Elem(), tag)
+	}
+}
+
+func formatMarshaler(v reflect.Value, _ *fieldTag) (string, error) {
+	b, err := v.Interface().(Marshaler).MarshalCNAB()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func formatAddrMarshaler(v reflect.Value, tag *fieldTag) (string, error) {
+	if !v.CanAddr() {
+		return "", ErrUnsupportedType
+	}
+	return formatMarshaler(v.Addr(), tag)
+}
+
+func formatString(v reflect.Value, _ *fieldTag) (string, error) {
+	return v.String(), nil
+}
+
+func formatInt(v reflect.Value, _ *fieldTag) (string, error) {
+	return strconv.FormatInt(v.Int(), 10), nil
+}
+
+func formatUint(v reflect.Value, _ *fieldTag) (string, error) {
+	return strconv.FormatUint(v.Uint(), 10), nil
+}
+
+func formatFloat(v reflect.Value, tag *fieldTag) (string, error) {
+	if tag.decimal > 0 {
+		// Go through Decimal so the value is scaled exactly
+		d, err := decimalFromFloat(v.Float(), v.Type().Bits())
+		if err != nil {
+			return "", err
+		}
+		return formatDecimal(d, tag), nil
+	}
+	return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+}
+
+func formatBool(v reflect.Value, tag *fieldTag) (string, error) {
+	t, f := tag.boolCodes()
+	if v.Bool() {
+		return t, nil
+	}
+	return f, nil
+}
+
+func formatDecimalValue(v reflect.Value, tag *fieldTag) (string, error) {
+	return formatDecimal(v.Interface().(Decimal), tag), nil
+}
+
+func formatTime(v reflect.Value, tag *fieldTag) (string, error) {
+	t := v.Interface().(time.Time)
+	if t.IsZero() {
+		return "", nil
+	}
+	f := tag.format
+	if f == "" {
+		f = "20060102" // Default CNAB date format
+	}
+	return t.Format(f), nil
+}
+
+// formatDecimal renders d as an implicit-decimal integer with tag.decimal
+// digits, or in plain notation when the tag declares no decimals.
+func formatDecimal(d Decimal, tag *fieldTag) string {
+	if tag.decimal > 0 {
+		return d.Rescale(tag.decimal, tag.rounding).Unscaled().String()
+	}
+	return d.String()
+}
+
+// isZero reports whether v is a zero value, honoring IsZero methods
+// such as time.Time's and Decimal's. Only nil pointers are zero.
+func isZero(v reflect.Value) bool {
+	if v.Kind() == reflect.Ptr {
+		return v.IsNil()
+	}
+	if v.CanInterface() {
+		if z, ok := v.Interface().(interface{ IsZero() bool }); ok {
+			return z.IsZero()
 		}
 	}
-	return "", ErrUnsupportedType
+	return v.IsZero()
 }
@@ -0,0 +1,183 @@
+package cnab
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Code is an allowed value of a coded field and its human label.
+type Code struct {
+	Code  string
+	Label string
+}
+
+// CodeSet is the list of values allowed in a coded field, such as the
+// carteira or the ocorrência codes of a bank.
+type CodeSet struct {
+	Name  string // empty for inline sets
+	Codes []Code
+}
+
+// Label returns the label of code.
+func (s *CodeSet) Label(code string) (string, bool) {
+	for _, c := range s.Codes {
+		if c.Code == code {
+			return c.Label, true
+		}
+	}
+	return "", false
+}
+
+// String lists the codes of s separated by commas.
+func (s *CodeSet) String() string {
+	codes := make([]string, len(s.Codes))
+	for i, c := range s.Codes {
+		codes[i] = c.Code
+	}
+	return strings.Join(codes, ", ")
+}
+
+var codeSets = struct {
+	sync.RWMutex
+	m map[string]*CodeSet
+}{m: make(map[string]*CodeSet)}
+
+// RegisterCodeSet makes a named code set available to `enum:name` tags.
+// Register sets before the structs that use them are first encoded or
+// decoded, typically in an init function.
+func RegisterCodeSet(name string, codes ...Code) error {
+	if name == "" || strings.ContainsAny(name, "|=;") {
+		return fmt.Errorf("%w: invalid code set name %q", ErrInvalidTag, name)
+	}
+	set := &CodeSet{Name: name, Codes: codes}
+	if err := set.check(); err != nil {
+		return err
+	}
+
+	codeSets.Lock()
+	defer codeSets.Unlock()
+	if _, ok := codeSets.m[name]; ok {
+		return fmt.Errorf("%w: code set %q already registered", ErrInvalidTag, name)
+	}
+	codeSets.m[name] = set
+	return nil
+}
+
+// ParseCodeSet resolves the value of an `enum` tag: either the name of a
+// registered set or an inline list such as "01|02|03" or
+// "01=Entrada|02=Baixa".
+func ParseCodeSet(spec string) (*CodeSet, error) {
+	if !strings.ContainsAny(spec, "|=") {
+		codeSets.RLock()
+		set, ok := codeSets.m[spec]
+		codeSets.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown code set %q", ErrInvalidTag, spec)
+		}
+		return set, nil
+	}
+
+	set := &CodeSet{}
+	for _, item := range strings.Split(spec, "|") {
+		code, label, _ := strings.Cut(item, "=")
+		set.Codes = append(set.Codes, Code{Code: strings.TrimSpace(code), Label: strings.TrimSpace(label)})
+	}
+	if err := set.check(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// check rejects empty sets and empty or repeated codes.
+func (s *CodeSet) check() error {
+	if len(s.Codes) == 0 {
+		return fmt.Errorf("%w: empty code set", ErrInvalidTag)
+	}
+	seen := make(map[string]bool, len(s.Codes))
+	for _, c := range s.Codes {
+		if c.Code == "" || strings.ContainsAny(c.Code, "|=;") || seen[c.Code] {
+			return fmt.Errorf("%w: invalid code %q in set", ErrInvalidTag, c.Code)
+		}
+		seen[c.Code] = true
+	}
+	return nil
+}
+
+// enumIndex maps the codes of set, normalized as the field stores them,
+// to their entries.
+func enumIndex(set *CodeSet, tag *fieldTag) (map[string]*Code, error) {
+	index := make(map[string]*Code, len(set.Codes))
+	for i := range set.Codes {
+		c := &set.Codes[i]
+		if runeCount(c.Code) > tag.size {
+			return nil, fmt.Errorf("%w: code %q longer than size %d", ErrInvalidTag, c.Code, tag.size)
+		}
+		index[trimCode(c.Code, tag)] = c
+	}
+	return index, nil
+}
+
+// trimCode strips the padding of a coded value, so "1" and "01" match
+// in a zero-filled, right-aligned field.
+func trimCode(s string, tag *fieldTag) string {
+	if tag.align == "right" {
+		s = strings.TrimLeft(s, string(tag.fill))
+	} else {
+		s = strings.TrimRight(s, string(tag.fill))
+	}
+	return strings.TrimSpace(s)
+}
+
+// checkCode verifies that s, the text of an enum field, is in its set.
+// Blank values are left to the required option.
+func (f *codecField) checkCode(s string) error {
+	code := trimCode(s, &f.tag)
+	if code == "" {
+		return nil
+	}
+	if _, ok := f.codes[code]; !ok {
+		return fmt.Errorf("%w %q: allowed %s", ErrInvalidCode, strings.TrimSpace(s), f.tag.enum)
+	}
+	return nil
+}
+
+// Label returns the label of the code held by the named field of v, which
+// must be a struct or a pointer to one with an `enum` tag on that field.
+func Label(v interface{}, field string) (string, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return "", ErrInvalidStruct
+	}
+
+	c, err := codecFor(rv.Type())
+	if err != nil {
+		return "", err
+	}
+	for _, f := range c.fields {
+		if f.name != field {
+			continue
+		}
+		if f.codes == nil {
+			return "", fmt.Errorf("field %s: %w: no enum", field, ErrInvalidTag)
+		}
+		val, ok := f.value(rv)
+		if !ok {
+			return "", nil
+		}
+		s, err := f.format(val, &f.tag)
+		if err != nil {
+			return "", f.fieldError(err, "")
+		}
+		code, ok := f.codes[trimCode(s, &f.tag)]
+		if !ok {
+			return "", f.fieldError(fmt.Errorf("%w %q: allowed %s", ErrInvalidCode, s, f.tag.enum), s)
+		}
+		return code.Label, nil
+	}
+	return "", fmt.Errorf("field %s: %w: no such field", field, ErrInvalidTag)
+}
@@ -0,0 +1,95 @@
+package cnab
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func init() {
+	err := RegisterCodeSet("test-ocorrencia",
+		Code{Code: "02", Label: "Entrada confirmada"},
+		Code{Code: "06", Label: "Liquidação"},
+		Code{Code: "09", Label: "Baixa"},
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+type enumRecord struct {
+	Carteira   string `cnab:"size:2;enum:01=Simples|02=Vinculada"`
+	Ocorrencia int    `cnab:"size:2;fill:0;align:right;enum:test-ocorrencia"`
+}
+
+func TestEnumRoundTrip(t *testing.T) {
+	in := enumRecord{Carteira: "02", Ocorrencia: 6}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != "0206" {
+		t.Fatalf("unexpected line: '%s'", data)
+	}
+
+	var out enumRecord
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out != in {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+
+	for field, want := range map[string]string{"Carteira": "Vinculada", "Ocorrencia": "Liquidação"} {
+		label, err := Label(&out, field)
+		if err != nil || label != want {
+			t.Errorf("Label(%s) = %q, %v; want %q", field, label, err, want)
+		}
+	}
+}
+
+func TestEnumInvalidCode(t *testing.T) {
+	_, err := Marshal(enumRecord{Carteira: "03", Ocorrencia: 2})
+
+	var fe *FieldError
+	if !errors.As(err, &fe) || !errors.Is(err, ErrInvalidCode) || fe.Field != "Carteira" {
+		t.Fatalf("expected ErrInvalidCode on Carteira, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"03"`) || !strings.Contains(err.Error(), "01, 02") {
+		t.Fatalf("expected bad code and allowed set in error, got %v", err)
+	}
+
+	var out enumRecord
+	err = Unmarshal([]byte("0107"), &out)
+	if !errors.As(err, &fe) || !errors.Is(err, ErrInvalidCode) || fe.Field != "Ocorrencia" {
+		t.Fatalf("expected ErrInvalidCode on Ocorrencia, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "02, 06, 09") {
+		t.Fatalf("expected allowed set in error, got %v", err)
+	}
+
+	// Blank optional codes are accepted
+	if err := Unmarshal([]byte("  00"), &out); err != nil {
+		t.Fatalf("expected blank codes to pass, got %v", err)
+	}
+}
+
+func TestEnumInvalidTag(t *testing.T) {
+	type Unknown struct {
+		Code string `cnab:"size:2;enum:no-such-set"`
+	}
+	type TooLong struct {
+		Code string `cnab:"size:2;enum:001|002"`
+	}
+
+	for _, v := range []interface{}{Unknown{}, TooLong{}} {
+		if _, err := Marshal(v); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%T: expected ErrInvalidTag, got %v", v, err)
+		}
+	}
+
+	if err := RegisterCodeSet("test-ocorrencia", Code{Code: "01"}); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected duplicate registration to fail, got %v", err)
+	}
+}
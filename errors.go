@@ -1,6 +1,11 @@
 package cnab
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
 
 var (
 	// ErrInvalidStruct indicates that a non-struct value was provided.
@@ -26,4 +31,173 @@ var (
 
 	// ErrInvalidDateFormat indicates that a date field could not be parsed with the provided format.
 	ErrInvalidDateFormat = errors.New("cnab: invalid date format")
+
+	// ErrUnknownRecord indicates that no layout is registered for a record's discriminator.
+	ErrUnknownRecord = errors.New("cnab: no layout registered for record")
+
+	// ErrTooManyOccurrences indicates that a repeated field holds more items than its repeat count.
+	ErrTooManyOccurrences = errors.New("cnab: too many occurrences")
+
+	// ErrRecordLength indicates that an encoded record does not match the declared file width.
+	ErrRecordLength = errors.New("cnab: record length mismatch")
+
+	// ErrFileStructure indicates that the records of a file are not in the expected order.
+	ErrFileStructure = errors.New("cnab: invalid file structure")
+
+	// ErrRequired indicates that a required field is zero on encode or blank on decode.
+	ErrRequired = errors.New("cnab: required field is empty")
+
+	// ErrFieldOverlap indicates that two fields claim the same position.
+	ErrFieldOverlap = errors.New("cnab: overlapping fields")
+
+	// ErrLayoutGap indicates that some positions of a record are not covered by any field.
+	ErrLayoutGap = errors.New("cnab: positions not covered by any field")
+
+	// ErrInvalidBool indicates that a bool field holds neither its true nor its false code.
+	ErrInvalidBool = errors.New("cnab: invalid boolean code")
+
+	// ErrInvalidCode indicates that an enum field holds a code outside its set.
+	ErrInvalidCode = errors.New("cnab: invalid code")
+
+	// ErrFieldOutOfRange indicates that a field ends beyond the declared record length.
+	ErrFieldOutOfRange = errors.New("cnab: field exceeds record length")
+
+	// ErrCharset indicates text that cannot be converted to or from the configured charset.
+	ErrCharset = errors.New("cnab: invalid character for charset")
+
+	// ErrIntegrity indicates that a trailer count or total does not match the records read.
+	ErrIntegrity = errors.New("cnab: trailer does not match records")
+
+	// ErrUnknownSum indicates that a sum names a field no record written so far has.
+	ErrUnknownSum = errors.New("cnab: summed field not found")
+
+	// ErrSequence indicates that a record number does not follow the previous one.
+	ErrSequence = errors.New("cnab: record out of sequence")
 )
+
+// IntegrityError reports a trailer count or total that differs from the
+// value computed from the records read.
+type IntegrityError struct {
+	Expected Decimal // computed from the records
+	Actual   Decimal // declared in the trailer
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("%v: expected %s, got %s", ErrIntegrity, e.Expected, e.Actual)
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return ErrIntegrity
+}
+
+// SequenceError reports a record number that breaks its sequence. The
+// Reader resumes counting from Actual, so a gap is reported once.
+type SequenceError struct {
+	Expected int
+	Actual   int
+}
+
+func (e *SequenceError) Error() string {
+	return fmt.Sprintf("%v: expected %d, got %d", ErrSequence, e.Expected, e.Actual)
+}
+
+func (e *SequenceError) Unwrap() error {
+	return ErrSequence
+}
+
+// FieldError describes a failure to encode or decode a single field.
+// It wraps the cause, so errors.Is works against the Err* sentinels.
+type FieldError struct {
+	Field string       // field name
+	Type  reflect.Type // Go type of the field
+	Start int          // 1-based start position
+	End   int          // 1-based end position (inclusive)
+	Raw   string       // raw field text, when available
+	Line  int          // 1-based line number, 0 when unknown
+	Err   error        // underlying cause
+}
+
+func (e *FieldError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	fmt.Fprintf(&b, "field %s", e.Field)
+	if e.Type != nil {
+		fmt.Fprintf(&b, " (%s)", e.Type)
+	}
+	if e.Start > 0 {
+		fmt.Fprintf(&b, " at %d-%d", e.Start, e.End)
+	}
+	if e.Raw != "" {
+		fmt.Fprintf(&b, " %q", e.Raw)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList aggregates the errors found when decoding in collect mode.
+// It unwraps to its elements, so errors.Is and errors.As inspect every entry.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (l ErrorList) Unwrap() []error {
+	return l
+}
+
+// add appends err to the list, flattening nested lists.
+func (l *ErrorList) add(err error) {
+	if nested, ok := err.(ErrorList); ok {
+		*l = append(*l, nested...)
+		return
+	}
+	*l = append(*l, err)
+}
+
+// err returns the list as an error, or nil when it is empty.
+func (l ErrorList) err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// joinErrors returns err, or an ErrorList of both when err is not nil.
+func joinErrors(err, more error) error {
+	if err == nil {
+		return more
+	}
+	var errs ErrorList
+	errs.add(err)
+	errs.add(more)
+	return errs
+}
+
+// withLine attaches a line number to the field errors in err.
+// Other errors are prefixed with the line number.
+func withLine(err error, line int) error {
+	if list, ok := err.(ErrorList); ok {
+		for i, e := range list {
+			list[i] = withLine(e, line)
+		}
+		return list
+	}
+
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		fe.Line = line
+		return err
+	}
+	return fmt.Errorf("line %d: %w", line, err)
+}
@@ -0,0 +1,80 @@
+package cnab
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeFieldError(t *testing.T) {
+	input := "001TEST      2023102500000A2345        "
+	var h TestHeader
+
+	err := Unmarshal([]byte(input), &h)
+
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *FieldError, got %T: %v", err, err)
+	}
+	if !errors.Is(err, ErrInvalidNumberFormat) {
+		t.Fatalf("expected ErrInvalidNumberFormat, got %v", err)
+	}
+
+	if fe.Field != "Amount" || fe.Type != reflect.TypeOf(float64(0)) {
+		t.Errorf("unexpected field identification: %s (%v)", fe.Field, fe.Type)
+	}
+	if fe.Start != 22 || fe.End != 31 {
+		t.Errorf("expected position 22-31, got %d-%d", fe.Start, fe.End)
+	}
+	if fe.Raw != "00000A2345" {
+		t.Errorf("expected raw '00000A2345', got '%s'", fe.Raw)
+	}
+}
+
+func TestEncodeFieldError(t *testing.T) {
+	_, err := Marshal(TestHeader{Name: "THIS NAME IS TOO LONG"})
+
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *FieldError, got %T: %v", err, err)
+	}
+	if !errors.Is(err, ErrFieldSizeMismatch) {
+		t.Fatalf("expected ErrFieldSizeMismatch, got %v", err)
+	}
+	if fe.Field != "Name" || fe.Start != 4 || fe.End != 13 {
+		t.Errorf("unexpected field error: %+v", fe)
+	}
+}
+
+func TestEncodeOverlapFieldError(t *testing.T) {
+	type Bad struct {
+		A string `cnab:"start:1;end:3"`
+		B string `cnab:"start:3;size:2"`
+	}
+
+	_, err := Marshal(Bad{A: "AAA", B: "BB"})
+	if !errors.Is(err, ErrFieldOverlap) {
+		t.Fatalf("expected ErrFieldOverlap, got %v", err)
+	}
+}
+
+func TestReaderFieldErrorLine(t *testing.T) {
+	r := newTestReader("0BANK      \n1000X0\n")
+
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	_, err := r.Read()
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *FieldError, got %T: %v", err, err)
+	}
+	if fe.Line != 2 {
+		t.Errorf("expected line 2, got %d", fe.Line)
+	}
+	if !strings.HasPrefix(err.Error(), "line 2: field Amount (int) at 2-6 \"000X0\"") {
+		t.Errorf("unexpected message: %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+// Package export flattens CNAB files into CSV or JSON Lines, one row per
+// detail record, with the fields of the preceding header records repeated
+// on every row.
+package export
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HigorGrigorio/cnab"
+)
+
+// ErrUnknownColumn indicates a selected column that no record provides.
+var ErrUnknownColumn = errors.New("export: unknown column")
+
+// Kind tells how the records of a type are exported.
+type Kind int
+
+const (
+	// Header records are not exported themselves; their fields are
+	// repeated on the rows of the details that follow them.
+	Header Kind = iota
+	// Detail records are exported as one row each.
+	Detail
+	// Trailer records are skipped.
+	Trailer
+)
+
+// RecordType describes a record type of a Source.
+type RecordType struct {
+	Name   string
+	Kind   Kind
+	Fields []string
+}
+
+// Source yields the decoded records of a file in order.
+type Source interface {
+	// Records returns the record types, in column order.
+	Records() []RecordType
+	// Next returns the name of the next record and its values, one per
+	// field of its RecordType, or io.EOF at the end of the input.
+	Next() (name string, values []interface{}, err error)
+}
+
+// Exporter writes the records of a Source as rows. Columns are named
+// "Record.Field".
+type Exporter struct {
+	w       rowWriter
+	columns []string
+}
+
+// NewCSV creates an Exporter that writes CSV with a header row to w.
+func NewCSV(w io.Writer) *Exporter {
+	return &Exporter{w: &csvWriter{w: csv.NewWriter(w)}}
+}
+
+// NewJSONLines creates an Exporter that writes one JSON object per row to
+// w, with keys in column order.
+func NewJSONLines(w io.Writer) *Exporter {
+	return &Exporter{w: &jsonWriter{w: bufio.NewWriter(w)}}
+}
+
+// Select sets the exported columns and their order, e.g. "Header.Bank",
+// "Detail.Amount". By default every field of the header and detail
+// records is exported, in the order of Source.Records.
+func (e *Exporter) Select(columns ...string) {
+	e.columns = columns
+}
+
+// column is a resolved output column.
+type column struct {
+	name   string
+	record int
+	field  int
+}
+
+// Export streams every detail record of src as a row.
+func (e *Exporter) Export(src Source) error {
+	records := src.Records()
+	index := make(map[string]int, len(records))
+	for i, rt := range records {
+		index[rt.Name] = i
+	}
+
+	columns, err := e.resolve(records, index)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+	}
+	if err := e.w.header(names); err != nil {
+		return err
+	}
+
+	latest := make([][]interface{}, len(records))
+	row := make([]interface{}, len(columns))
+	for {
+		name, values, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		i, ok := index[name]
+		if !ok {
+			return fmt.Errorf("export: record %q not described by the source", name)
+		}
+		switch records[i].Kind {
+		case Header:
+			latest[i] = values
+			continue
+		case Trailer:
+			continue
+		}
+
+		for j, c := range columns {
+			row[j] = nil
+			if c.record == i {
+				row[j] = values[c.field]
+			} else if records[c.record].Kind == Header && latest[c.record] != nil {
+				row[j] = latest[c.record][c.field]
+			}
+		}
+		if err := e.w.row(names, row); err != nil {
+			return err
+		}
+	}
+
+	return e.w.flush()
+}
+
+// resolve returns the selected columns, or all header and detail fields.
+func (e *Exporter) resolve(records []RecordType, index map[string]int) ([]column, error) {
+	if len(e.columns) == 0 {
+		var columns []column
+		for i, rt := range records {
+			if rt.Kind == Trailer {
+				continue
+			}
+			for j, f := range rt.Fields {
+				columns = append(columns, column{name: rt.Name + "." + f, record: i, field: j})
+			}
+		}
+		return columns, nil
+	}
+
+	columns := make([]column, len(e.columns))
+	for i, name := range e.columns {
+		// Field names may contain dots, record names may not
+		rec, field, _ := strings.Cut(name, ".")
+		r, ok := index[rec]
+		if !ok || records[r].Kind == Trailer {
+			return nil, fmt.Errorf("%w %q", ErrUnknownColumn, name)
+		}
+		f := -1
+		for j, name := range records[r].Fields {
+			if name == field {
+				f = j
+			}
+		}
+		if f < 0 {
+			return nil, fmt.Errorf("%w %q", ErrUnknownColumn, name)
+		}
+		columns[i] = column{name: name, record: r, field: f}
+	}
+	return columns, nil
+}
+
+// rowWriter encodes rows in an output format.
+type rowWriter interface {
+	header(names []string) error
+	row(names []string, values []interface{}) error
+	flush() error
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (w *csvWriter) header(names []string) error {
+	w.record = make([]string, len(names))
+	return w.w.Write(names)
+}
+
+func (w *csvWriter) row(_ []string, values []interface{}) error {
+	for i, v := range values {
+		w.record[i], _ = text(v)
+	}
+	return w.w.Write(w.record)
+}
+
+func (w *csvWriter) flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type jsonWriter struct {
+	w *bufio.Writer
+}
+
+func (w *jsonWriter) header([]string) error {
+	return nil
+}
+
+func (w *jsonWriter) row(names []string, values []interface{}) error {
+	w.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(names[i])
+		w.w.Write(key)
+		w.w.WriteByte(':')
+
+		s, kind := text(v)
+		var val []byte
+		switch kind {
+		case null:
+			val = []byte("null")
+		case literal:
+			val = []byte(s)
+		default:
+			val, _ = json.Marshal(s)
+		}
+		if _, err := w.w.Write(val); err != nil {
+			return err
+		}
+	}
+	_, err := w.w.WriteString("}\n")
+	return err
+}
+
+func (w *jsonWriter) flush() error {
+	return w.w.Flush()
+}
+
+// valueKind tells how a formatted value is written in JSON.
+type valueKind int
+
+const (
+	quoted  valueKind = iota // JSON string
+	literal                  // JSON number or boolean
+	null                     // missing value
+)
+
+// text formats v for export: dates in ISO 8601 (date only at midnight),
+// decimals and floats as plain decimal strings, integers and booleans as
+// JSON literals, nil pointers as missing values.
+func text(v interface{}) (string, valueKind) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "", null
+		}
+		return text(rv.Elem().Interface())
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return "", null
+	case string:
+		return val, quoted
+	case time.Time:
+		if val.IsZero() {
+			return "", null
+		}
+		if val.Hour() == 0 && val.Minute() == 0 && val.Second() == 0 && val.Nanosecond() == 0 {
+			return val.Format("2006-01-02"), quoted
+		}
+		return val.Format(time.RFC3339Nano), quoted
+	case cnab.Decimal:
+		return val.String(), quoted
+	case encoding.TextMarshaler:
+		b, err := val.MarshalText()
+		if err == nil {
+			return string(b), quoted
+		}
+	case fmt.Stringer:
+		return val.String(), quoted
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), literal
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), literal
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, rv.Type().Bits()), quoted
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool()), literal
+	case reflect.String:
+		return rv.String(), quoted
+	}
+	return fmt.Sprint(v), quoted
+}
@@ -0,0 +1,140 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/HigorGrigorio/cnab"
+	"github.com/HigorGrigorio/cnab/dynamic"
+)
+
+type retornoHeader struct {
+	Type string    `cnab:"literal:0"`
+	Bank int       `cnab:"size:3;fill:0;align:right"`
+	Date time.Time `cnab:"size:8"`
+	_    string    `cnab:"size:8;literal:RETORNO "`
+}
+
+type retornoDetail struct {
+	Type   string       `cnab:"literal:1"`
+	Doc    string       `cnab:"size:10"`
+	Amount cnab.Decimal `cnab:"size:9;fill:0;align:right;decimal:2"`
+}
+
+type retornoTrailer struct {
+	Type  string `cnab:"literal:9"`
+	Count int    `cnab:"size:19;fill:0;align:right"`
+}
+
+const retornoFile = "023720240131RETORNO \r\n" +
+	"1DOC-1     000001050\r\n" +
+	"1DOC-2     000000001\r\n" +
+	"90000000000000000002\r\n"
+
+func newStructSource(t *testing.T, data string) *StructSource {
+	t.Helper()
+	r := cnab.NewReader(strings.NewReader(data))
+	r.SetDiscriminator(cnab.Position(1, 1))
+
+	src := NewStructSource(r)
+	for _, err := range []error{
+		src.Header("0", retornoHeader{}),
+		src.Detail("1", retornoDetail{}),
+		src.Trailer("9", retornoTrailer{}),
+	} {
+		if err != nil {
+			t.Fatalf("register failed: %v", err)
+		}
+	}
+	return src
+}
+
+func TestExportCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewCSV(&buf).Export(newStructSource(t, retornoFile)); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	expected := "retornoHeader.Type,retornoHeader.Bank,retornoHeader.Date,retornoDetail.Type,retornoDetail.Doc,retornoDetail.Amount\n" +
+		"0,237,2024-01-31,1,DOC-1,10.50\n" +
+		"0,237,2024-01-31,1,DOC-2,0.01\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected CSV:\nGot:\n%s\nWant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestExportJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewJSONLines(&buf)
+	e.Select("retornoDetail.Amount", "retornoHeader.Bank", "retornoHeader.Date")
+
+	if err := e.Export(newStructSource(t, retornoFile)); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	expected := `{"retornoDetail.Amount":"10.50","retornoHeader.Bank":237,"retornoHeader.Date":"2024-01-31"}` + "\n" +
+		`{"retornoDetail.Amount":"0.01","retornoHeader.Bank":237,"retornoHeader.Date":"2024-01-31"}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected JSON Lines:\nGot:\n%s\nWant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestExportLayout(t *testing.T) {
+	l, err := dynamic.LoadLayout(strings.NewReader(`
+version: 1
+recordLength: 20
+records:
+  - name: header
+    match: [{start: 1, value: "0"}]
+    fields:
+      - {name: Bank, start: 2, size: 3, type: int}
+      - {name: Date, size: 8, type: date}
+  - name: detail
+    match: [{start: 1, value: "1"}]
+    fields:
+      - {name: Doc, start: 2, size: 10}
+      - {name: Amount, size: 9, type: decimal, decimal: 2}
+  - name: trailer
+    match: [{start: 1, value: "9"}]
+    fields:
+      - {name: Count, start: 2, size: 19, type: int}
+`))
+	if err != nil {
+		t.Fatalf("LoadLayout failed: %v", err)
+	}
+
+	src := NewLayoutSource(strings.NewReader(retornoFile), l)
+	src.Detail("detail")
+	src.Trailer("trailer")
+
+	var buf bytes.Buffer
+	e := NewJSONLines(&buf)
+	e.Select("header.Date", "detail.Doc", "detail.Amount")
+	if err := e.Export(src); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	expected := `{"header.Date":"2024-01-31","detail.Doc":"DOC-1","detail.Amount":"10.50"}` + "\n" +
+		`{"header.Date":"2024-01-31","detail.Doc":"DOC-2","detail.Amount":"0.01"}` + "\n"
+	if buf.String() != expected {
+		t.Fatalf("unexpected JSON Lines:\nGot:\n%s\nWant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestExportErrors(t *testing.T) {
+	for _, col := range []string{"retornoDetail.Missing", "Nope.Doc", "retornoTrailer.Count"} {
+		e := NewCSV(new(bytes.Buffer))
+		e.Select(col)
+		if err := e.Export(newStructSource(t, retornoFile)); !errors.Is(err, ErrUnknownColumn) {
+			t.Errorf("%s: expected ErrUnknownColumn, got %v", col, err)
+		}
+	}
+
+	err := NewCSV(new(bytes.Buffer)).Export(newStructSource(t, "1DOC-1     00000ABCD\r\n"))
+	if !errors.Is(err, cnab.ErrInvalidNumberFormat) {
+		t.Fatalf("expected decode error, got %v", err)
+	}
+}
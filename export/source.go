@@ -0,0 +1,186 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/HigorGrigorio/cnab"
+	"github.com/HigorGrigorio/cnab/dynamic"
+)
+
+// StructSource reads records through a cnab.Reader. Record types are named
+// after their Go struct type.
+type StructSource struct {
+	r       *cnab.Reader
+	records []RecordType
+	fields  [][]int // positions in cnab.Values of the exported fields of each record type
+	types   map[reflect.Type]int
+}
+
+// NewStructSource creates a Source over r. The Reader's discriminator must
+// be set; record types are registered with Header, Detail and Trailer.
+func NewStructSource(r *cnab.Reader) *StructSource {
+	return &StructSource{r: r, types: make(map[reflect.Type]int)}
+}
+
+// Header registers the struct type of v under key as a header record.
+func (s *StructSource) Header(key string, v interface{}) error {
+	return s.register(key, v, Header)
+}
+
+// Detail registers the struct type of v under key as a detail record.
+func (s *StructSource) Detail(key string, v interface{}) error {
+	return s.register(key, v, Detail)
+}
+
+// Trailer registers the struct type of v under key as a trailer record.
+func (s *StructSource) Trailer(key string, v interface{}) error {
+	return s.register(key, v, Trailer)
+}
+
+func (s *StructSource) register(key string, v interface{}, kind Kind) error {
+	specs, err := cnab.Describe(v)
+	if err != nil {
+		return err
+	}
+	if err := s.r.Register(key, v); err != nil {
+		return err
+	}
+
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if _, ok := s.types[t]; ok {
+		return nil
+	}
+
+	rt := RecordType{Name: t.Name(), Kind: kind}
+	var fields []int
+	for i, spec := range specs {
+		// Unexported fields are literal fillers
+		if exported(spec.Name) {
+			rt.Fields = append(rt.Fields, spec.Name)
+			fields = append(fields, i)
+		}
+	}
+	s.types[t] = len(s.records)
+	s.records = append(s.records, rt)
+	s.fields = append(s.fields, fields)
+	return nil
+}
+
+// Records implements Source.
+func (s *StructSource) Records() []RecordType {
+	return s.records
+}
+
+// Next implements Source.
+func (s *StructSource) Next() (string, []interface{}, error) {
+	rec, err := s.r.Read()
+	if err != nil {
+		return "", nil, err
+	}
+
+	all, err := cnab.Values(rec.Value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	i := s.types[reflect.TypeOf(rec.Value).Elem()]
+	values := make([]interface{}, len(s.fields[i]))
+	for j, k := range s.fields[i] {
+		values[j] = all[k]
+	}
+	return s.records[i].Name, values, nil
+}
+
+// exported reports whether every segment of a field path such as
+// Pagador.Endereco.CEP or Mensagens[0] is exported.
+func exported(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			part = part[:i]
+		}
+		if !token.IsExported(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// LayoutSource reads records described by a dynamic layout. Records are
+// headers unless marked with Detail or Trailer.
+type LayoutSource struct {
+	scanner *bufio.Scanner
+	layout  *dynamic.Layout
+	kinds   map[string]Kind
+	line    int
+}
+
+// NewLayoutSource creates a Source reading lines of r with layout l.
+func NewLayoutSource(r io.Reader, l *dynamic.Layout) *LayoutSource {
+	return &LayoutSource{scanner: bufio.NewScanner(r), layout: l, kinds: make(map[string]Kind)}
+}
+
+// Detail marks the named records as details.
+func (s *LayoutSource) Detail(names ...string) {
+	for _, name := range names {
+		s.kinds[name] = Detail
+	}
+}
+
+// Trailer marks the named records as trailers.
+func (s *LayoutSource) Trailer(names ...string) {
+	for _, name := range names {
+		s.kinds[name] = Trailer
+	}
+}
+
+// Records implements Source.
+func (s *LayoutSource) Records() []RecordType {
+	records := make([]RecordType, len(s.layout.Records))
+	for i, rec := range s.layout.Records {
+		records[i] = RecordType{Name: rec.Name, Kind: s.kinds[rec.Name]}
+		for _, f := range rec.Fields {
+			records[i].Fields = append(records[i].Fields, f.Name)
+		}
+	}
+	return records
+}
+
+// Next implements Source. Empty lines and a trailing EOF marker are skipped.
+func (s *LayoutSource) Next() (string, []interface{}, error) {
+	for s.scanner.Scan() {
+		s.line++
+		line := s.scanner.Bytes()
+		if len(bytes.Trim(line, "\x1a")) == 0 {
+			continue
+		}
+
+		rec, err := s.layout.Match(line)
+		if err != nil {
+			return "", nil, fmt.Errorf("line %d: %w", s.line, err)
+		}
+		data, err := dynamic.Unmarshal(line, rec.Fields)
+		if err != nil {
+			return "", nil, fmt.Errorf("line %d: %w", s.line, err)
+		}
+
+		values := make([]interface{}, len(rec.Fields))
+		for i, f := range rec.Fields {
+			values[i] = data[f.Name]
+		}
+		return rec.Name, values, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return "", nil, err
+	}
+	return "", nil, io.EOF
+}
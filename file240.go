@@ -0,0 +1,196 @@
+package cnab
+
+import (
+	"fmt"
+	"io"
+)
+
+// CNAB 240 record types (position 8).
+const (
+	RecordFileHeader  = "0"
+	RecordLotHeader   = "1"
+	RecordDetail      = "3"
+	RecordLotTrailer  = "5"
+	RecordFileTrailer = "9"
+)
+
+// File is the tree of a CNAB 240 file: file header, lots and file trailer.
+type File struct {
+	Header  interface{}
+	Lots    []Lot
+	Trailer interface{}
+}
+
+// Lot is a batch of a CNAB 240 file: lot header, segment records and lot trailer.
+type Lot struct {
+	Header  interface{}
+	Details []interface{}
+	Trailer interface{}
+}
+
+// Layout240 maps the records of a CNAB 240 file to tagged struct types.
+// Each entry may be a struct value or a pointer to one.
+type Layout240 struct {
+	FileHeader  interface{}
+	LotHeader   interface{}
+	Segments    map[string]interface{} // keyed by segment code (position 14), e.g. "P", "Q"
+	LotTrailer  interface{}
+	FileTrailer interface{}
+}
+
+// Discriminator240 keys CNAB 240 lines by record type (position 8).
+// Detail records are keyed by record type followed by the segment code
+// (position 14), e.g. "3P".
+func Discriminator240(line []byte) string {
+	key := Position(8, 8)(line)
+	if key == RecordDetail {
+		key += Position(14, 14)(line)
+	}
+	return key
+}
+
+// ReadFile240 reads a whole CNAB 240 file into a File tree, routing each
+// line to the struct type declared in layout.
+func (r *Reader) ReadFile240(layout *Layout240) (*File, error) {
+	if err := r.register240(layout); err != nil {
+		return nil, err
+	}
+	r.SetDiscriminator(Discriminator240)
+	r.SetLotKeys(RecordLotHeader, RecordLotTrailer)
+
+	b := &fileBuilder{file: &File{}}
+	var errs ErrorList
+
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if !r.canContinue(rec, err) {
+				return nil, err
+			}
+			errs.add(err)
+			if rec == nil {
+				continue
+			}
+		}
+
+		if err := b.add(rec); err != nil {
+			if !r.collect {
+				return nil, err
+			}
+			errs.add(err)
+		}
+	}
+
+	if !b.seenTrailer {
+		err := fmt.Errorf("%w: missing file trailer", ErrFileStructure)
+		if !r.collect {
+			return nil, err
+		}
+		errs.add(err)
+	}
+
+	return b.file, errs.err()
+}
+
+// fileBuilder assembles a File tree from records in file order.
+type fileBuilder struct {
+	file        *File
+	lot         *Lot
+	seenHeader  bool
+	seenTrailer bool
+}
+
+// add places rec in the tree, rejecting records out of order.
+func (b *fileBuilder) add(rec *Record) error {
+	if b.seenTrailer {
+		return structureError(rec, "record after file trailer")
+	}
+
+	switch kind := rec.Key[:1]; {
+	case kind == RecordFileHeader:
+		if b.seenHeader {
+			return structureError(rec, "duplicate file header")
+		}
+		b.seenHeader = true
+		b.file.Header = rec.Value
+	case !b.seenHeader:
+		return structureError(rec, "missing file header")
+	case kind == RecordLotHeader:
+		if b.lot != nil {
+			return structureError(rec, "lot header inside an open lot")
+		}
+		b.lot = &Lot{Header: rec.Value}
+	case kind == RecordDetail:
+		if b.lot == nil {
+			return structureError(rec, "detail record outside a lot")
+		}
+		b.lot.Details = append(b.lot.Details, rec.Value)
+	case kind == RecordLotTrailer:
+		if b.lot == nil {
+			return structureError(rec, "lot trailer without lot header")
+		}
+		b.lot.Trailer = rec.Value
+		b.file.Lots = append(b.file.Lots, *b.lot)
+		b.lot = nil
+	case kind == RecordFileTrailer:
+		if b.lot != nil {
+			return structureError(rec, "file trailer inside an open lot")
+		}
+		b.seenTrailer = true
+		b.file.Trailer = rec.Value
+	}
+	return nil
+}
+
+func (r *Reader) register240(layout *Layout240) error {
+	entries := map[string]interface{}{
+		RecordFileHeader:  layout.FileHeader,
+		RecordLotHeader:   layout.LotHeader,
+		RecordLotTrailer:  layout.LotTrailer,
+		RecordFileTrailer: layout.FileTrailer,
+	}
+	for code, v := range layout.Segments {
+		entries[RecordDetail+code] = v
+	}
+
+	for key, v := range entries {
+		if err := r.Register(key, v); err != nil {
+			return fmt.Errorf("record %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func structureError(rec *Record, msg string) error {
+	return fmt.Errorf("line %d: %w: %s", rec.Line, ErrFileStructure, msg)
+}
+
+// WriteFile writes a CNAB 240 File tree: file header, each lot
+// (header, details, trailer) and the file trailer.
+func (w *Writer) WriteFile(f *File) error {
+	if err := w.Write(f.Header); err != nil {
+		return fmt.Errorf("file header: %w", err)
+	}
+
+	for i, lot := range f.Lots {
+		if err := w.BeginLot(lot.Header); err != nil {
+			return fmt.Errorf("lot %d header: %w", i+1, err)
+		}
+		for j, d := range lot.Details {
+			if err := w.Write(d); err != nil {
+				return fmt.Errorf("lot %d detail %d: %w", i+1, j+1, err)
+			}
+		}
+		if err := w.EndLot(lot.Trailer); err != nil {
+			return fmt.Errorf("lot %d trailer: %w", i+1, err)
+		}
+	}
+
+	if err := w.Write(f.Trailer); err != nil {
+		return fmt.Errorf("file trailer: %w", err)
+	}
+	return nil
+}
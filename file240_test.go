@@ -0,0 +1,141 @@
+package cnab
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Reduced CNAB 240 records: bank (1-3), lot (4-7), record type (8),
+// sequence (9-13), segment (14) and a value (15-20).
+type header240 struct {
+	Bank string `cnab:"size:3"`
+	Lot  int    `cnab:"size:4;fill:0;align:right"`
+	Type string `cnab:"literal:0"`
+	Name string `cnab:"size:12"`
+}
+
+type lotHeader240 struct {
+	Bank string `cnab:"size:3"`
+	Lot  int    `cnab:"size:4;fill:0;align:right"`
+	Type string `cnab:"literal:1"`
+	Op   string `cnab:"size:12"`
+}
+
+type segmentP240 struct {
+	Bank    string `cnab:"size:3"`
+	Lot     int    `cnab:"size:4;fill:0;align:right"`
+	Type    string `cnab:"literal:3"`
+	Seq     int    `cnab:"size:5;fill:0;align:right"`
+	Segment string `cnab:"literal:P"`
+	Amount  int    `cnab:"size:6;fill:0;align:right"`
+}
+
+type segmentQ240 struct {
+	Bank    string `cnab:"size:3"`
+	Lot     int    `cnab:"size:4;fill:0;align:right"`
+	Type    string `cnab:"literal:3"`
+	Seq     int    `cnab:"size:5;fill:0;align:right"`
+	Segment string `cnab:"literal:Q"`
+	Name    string `cnab:"size:6"`
+}
+
+type lotTrailer240 struct {
+	Bank  string `cnab:"size:3"`
+	Lot   int    `cnab:"size:4;fill:0;align:right"`
+	Type  string `cnab:"literal:5"`
+	Count int    `cnab:"size:12;fill:0;align:right"`
+}
+
+type trailer240 struct {
+	Bank string `cnab:"size:3"`
+	Lot  int    `cnab:"size:4;fill:0;align:right"`
+	Type string `cnab:"literal:9"`
+	Lots int    `cnab:"size:12;fill:0;align:right"`
+}
+
+var testLayout240 = &Layout240{
+	FileHeader:  header240{},
+	LotHeader:   lotHeader240{},
+	Segments:    map[string]interface{}{"P": segmentP240{}, "Q": segmentQ240{}},
+	LotTrailer:  lotTrailer240{},
+	FileTrailer: trailer240{},
+}
+
+const testFile240 = "00100000BANK        \r\n" +
+	"00100011COBRANCA    \r\n" +
+	"0010001300001P000150\r\n" +
+	"0010001300002QJOHN  \r\n" +
+	"00100015000000000004\r\n" +
+	"00199999000000000001\r\n"
+
+func TestReadFile240(t *testing.T) {
+	f, err := NewReader(strings.NewReader(testFile240)).ReadFile240(testLayout240)
+	if err != nil {
+		t.Fatalf("ReadFile240 failed: %v", err)
+	}
+
+	if h, ok := f.Header.(*header240); !ok || h.Name != "BANK" {
+		t.Fatalf("unexpected file header: %#v", f.Header)
+	}
+	if len(f.Lots) != 1 {
+		t.Fatalf("expected 1 lot, got %d", len(f.Lots))
+	}
+
+	lot := f.Lots[0]
+	if len(lot.Details) != 2 {
+		t.Fatalf("expected 2 details, got %d", len(lot.Details))
+	}
+	if p, ok := lot.Details[0].(*segmentP240); !ok || p.Amount != 150 {
+		t.Fatalf("unexpected segment P: %#v", lot.Details[0])
+	}
+	if q, ok := lot.Details[1].(*segmentQ240); !ok || q.Name != "JOHN" {
+		t.Fatalf("unexpected segment Q: %#v", lot.Details[1])
+	}
+	if tr, ok := lot.Trailer.(*lotTrailer240); !ok || tr.Count != 4 {
+		t.Fatalf("unexpected lot trailer: %#v", lot.Trailer)
+	}
+	if tr, ok := f.Trailer.(*trailer240); !ok || tr.Lots != 1 {
+		t.Fatalf("unexpected file trailer: %#v", f.Trailer)
+	}
+}
+
+func TestWriteFile240RoundTrip(t *testing.T) {
+	f, err := NewReader(strings.NewReader(testFile240)).ReadFile240(testLayout240)
+	if err != nil {
+		t.Fatalf("ReadFile240 failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetRecordLength(20)
+	if err := w.WriteFile(f); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if buf.String() != testFile240 {
+		t.Fatalf("round trip mismatch:\nGot:  %q\nWant: %q", buf.String(), testFile240)
+	}
+}
+
+func TestReadFile240Structure(t *testing.T) {
+	// Detail record before any lot header.
+	input := "00100000BANK        \n" +
+		"0010001300001P000150\n"
+
+	_, err := NewReader(strings.NewReader(input)).ReadFile240(testLayout240)
+	if !errors.Is(err, ErrFileStructure) {
+		t.Fatalf("expected ErrFileStructure, got %v", err)
+	}
+
+	// Truncated file without trailer.
+	input = "00100000BANK        \n"
+	_, err = NewReader(strings.NewReader(input)).ReadFile240(testLayout240)
+	if !errors.Is(err, ErrFileStructure) {
+		t.Fatalf("expected ErrFileStructure for missing trailer, got %v", err)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/HigorGrigorio/cnab
 
 go 1.25.0
 
-require github.com/pkg/errors v0.9.1
+require (
+	github.com/pkg/errors v0.9.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,106 @@
+package cnab
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReaderVerifyTotals(t *testing.T) {
+	newReader := func(data string) *Reader {
+		r := NewReader(strings.NewReader(data))
+		r.SetDiscriminator(Position(1, 1))
+		r.Register("0", aggHeader{})
+		r.Register("1", aggDetail{})
+		r.Register("9", aggTrailer{})
+		r.VerifyTotals()
+		return r
+	}
+
+	valid := "0BANK     \n1000105002\n1000025003\n90040013005\n"
+	if _, err := newReader(valid).ReadAll(); err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	// The second detail is missing
+	r := newReader("0BANK     \n1000105002\n90040013005\n")
+	r.CollectErrors()
+	_, err := r.ReadAll()
+
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 3 || !errors.Is(err, ErrIntegrity) {
+		t.Fatalf("expected three integrity errors, got %v", err)
+	}
+	var fe *FieldError
+	var ie *IntegrityError
+	if !errors.As(list[0], &fe) || !errors.As(list[0], &ie) || fe.Field != "Records" || fe.Line != 3 {
+		t.Fatalf("unexpected error: %v", list[0])
+	}
+	if ie.Expected.String() != "3" || ie.Actual.String() != "4" {
+		t.Fatalf("unexpected values: %v", ie)
+	}
+	if !errors.As(list[1], &ie) || ie.Expected.String() != "10.50" || ie.Actual.String() != "13.00" {
+		t.Fatalf("unexpected total error: %v", list[1])
+	}
+}
+
+func TestReaderVerifyLotTotals(t *testing.T) {
+	data := "0FILE     \n" +
+		"0LOT      \n1000010000\n1000025000\n504000350\n" +
+		"0LOT      \n1000000500\n503000006\n" +
+		"90209\n"
+
+	r := NewReader(strings.NewReader(data))
+	r.SetDiscriminator(func(line []byte) string {
+		if strings.HasPrefix(string(line), "0LOT") {
+			return "L"
+		}
+		return Position(1, 1)(line)
+	})
+	r.Register("0", aggHeader{})
+	r.Register("L", aggHeader{})
+	r.Register("1", aggDetail{})
+	r.Register("5", aggLotTrailer{})
+	r.Register("9", aggFileTrailer{})
+	r.SetLotKeys("L", "5")
+	r.VerifyTotals()
+
+	_, err := r.ReadAll()
+	var fe *FieldError
+	var ie *IntegrityError
+	if !errors.As(err, &fe) || !errors.As(err, &ie) || fe.Field != "Total" || fe.Line != 8 {
+		t.Fatalf("expected integrity error on the second lot total, got %v", err)
+	}
+	if ie.Expected.String() != "0.05" || ie.Actual.String() != "0.06" {
+		t.Fatalf("unexpected values: %v", ie)
+	}
+}
+
+func TestReaderVerifyTotalsPastBadFields(t *testing.T) {
+	type Detail struct {
+		Type   string `cnab:"literal:1"`
+		D      *int   `cnab:"size:3;blank:-"`
+		Amount int    `cnab:"size:3;fill:0;align:right"`
+	}
+	type Trailer struct {
+		Type  string `cnab:"literal:9"`
+		Total int    `cnab:"size:3;fill:0;align:right;sum:Amount"`
+	}
+
+	// D is blank in the first detail and invalid in the second; Amount
+	// is summed in both.
+	r := NewReader(strings.NewReader("1---005\n1x1b002\n9007\n"))
+	r.SetDiscriminator(Position(1, 1))
+	r.Register("1", Detail{})
+	r.Register("9", Trailer{})
+	r.VerifyTotals()
+	r.CollectErrors()
+
+	_, err := r.ReadAll()
+	if !errors.Is(err, ErrInvalidNumberFormat) {
+		t.Fatalf("expected the invalid field to be reported, got %v", err)
+	}
+	if errors.Is(err, ErrIntegrity) {
+		t.Fatalf("unexpected integrity error: %v", err)
+	}
+}
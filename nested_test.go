@@ -0,0 +1,89 @@
+package cnab
+
+import (
+	"errors"
+	"testing"
+)
+
+type nestedEndereco struct {
+	Rua string `cnab:"size:10"`
+	CEP int    `cnab:"size:8;fill:0;align:right"`
+}
+
+type nestedPagador struct {
+	Nome     string         `cnab:"size:6"`
+	Endereco nestedEndereco `cnab:"inline"`
+}
+
+type NestedConta struct {
+	Agencia int `cnab:"size:4;fill:0;align:right"`
+	Conta   int `cnab:"size:5;fill:0;align:right"`
+}
+
+type nestedRecord struct {
+	Tipo string `cnab:"literal:1"`
+	NestedConta
+	Pagador nestedPagador `cnab:"start:20"`
+	Fim     string        `cnab:"size:2"`
+	skipped nestedEndereco
+}
+
+func TestNestedRoundTrip(t *testing.T) {
+	in := nestedRecord{
+		Tipo:        "1",
+		NestedConta: NestedConta{Agencia: 123, Conta: 4567},
+		Pagador:     nestedPagador{Nome: "ANA", Endereco: nestedEndereco{Rua: "RUA A", CEP: 1310100}},
+		Fim:         "OK",
+	}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := "1012304567" + "         " + "ANA   " + "RUA A     " + "01310100" + "OK"
+	if string(data) != want {
+		t.Fatalf("unexpected line:\nGot:  '%s'\nWant: '%s'", data, want)
+	}
+
+	var out nestedRecord
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out != in {
+		t.Fatalf("round trip mismatch: %+v", out)
+	}
+
+	specs, err := Describe(out)
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if s := specs[5]; s.Name != "Pagador.Endereco.CEP" || s.Start != 36 || s.End != 43 {
+		t.Fatalf("unexpected spec: %+v", s)
+	}
+}
+
+func TestNestedErrorPath(t *testing.T) {
+	var out nestedRecord
+	line := "1012304567" + "         " + "ANA   " + "RUA A     " + "0131X100" + "OK"
+
+	err := Unmarshal([]byte(line), &out)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "Pagador.Endereco.CEP" || fe.Start != 36 {
+		t.Fatalf("expected error on Pagador.Endereco.CEP, got %v", err)
+	}
+}
+
+func TestNestedInvalidTag(t *testing.T) {
+	type Sized struct {
+		Endereco nestedEndereco `cnab:"size:18"`
+	}
+	type Hidden struct {
+		endereco nestedEndereco `cnab:"inline"`
+	}
+
+	for _, v := range []interface{}{Sized{}, Hidden{}} {
+		if _, err := Marshal(v); err == nil {
+			t.Errorf("%T: expected error", v)
+		}
+	}
+}
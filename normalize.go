@@ -0,0 +1,89 @@
+package cnab
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Normalizer makes text safe for banks that accept only a restricted set
+// of characters, typically uppercase ASCII without accents.
+type Normalizer struct {
+	Transliterate bool   // replace Latin letters with accents by their ASCII base, e.g. "ç" by "c"
+	Upper         bool   // convert letters to uppercase
+	Allowed       string // characters kept; empty means printable ASCII
+	Replacement   rune   // written for characters not allowed; zero drops them
+}
+
+// NewNormalizer returns a Normalizer that transliterates, uppercases and
+// drops anything outside printable ASCII.
+func NewNormalizer() *Normalizer {
+	return &Normalizer{Transliterate: true, Upper: true}
+}
+
+var defaultNormalizer = NewNormalizer()
+
+// Normalize returns s transliterated, uppercased and restricted to the
+// allowed characters, according to n.
+func (n *Normalizer) Normalize(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for _, r := range s {
+		if ascii, ok := transliterations[r]; ok && n.Transliterate {
+			for _, r := range ascii {
+				n.write(&b, r)
+			}
+			continue
+		}
+		n.write(&b, r)
+	}
+	return b.String()
+}
+
+// write appends r to b, or its replacement when it is not allowed.
+func (n *Normalizer) write(b *strings.Builder, r rune) {
+	if n.Upper {
+		r = unicode.ToUpper(r)
+	}
+	if n.allowed(r) {
+		b.WriteRune(r)
+	} else if n.Replacement != 0 {
+		b.WriteRune(n.Replacement)
+	}
+}
+
+func (n *Normalizer) allowed(r rune) bool {
+	if n.Allowed == "" {
+		return r >= ' ' && r <= '~'
+	}
+	return strings.ContainsRune(n.Allowed, r)
+}
+
+// transliterations maps Latin letters and typographic signs to ASCII.
+var transliterations = func() map[rune]string {
+	m := make(map[rune]string)
+	for ascii, chars := range map[string]string{
+		"A": "ÀÁÂÃÄÅĀĂĄ", "a": "àáâãäåāăąª",
+		"C": "ÇĆĈĊČ", "c": "çćĉċč",
+		"D": "ÐĎĐ", "d": "ðďđ",
+		"E": "ÈÉÊËĒĔĖĘĚ", "e": "èéêëēĕėęě",
+		"G": "ĜĞĠĢ", "g": "ĝğġģ",
+		"I": "ÌÍÎÏĨĪĬĮİ", "i": "ìíîïĩīĭįı",
+		"L": "ĹĻĽĿŁ", "l": "ĺļľŀł",
+		"N": "ÑŃŅŇ", "n": "ñńņň",
+		"O": "ÒÓÔÕÖØŌŎŐ", "o": "òóôõöøōŏőº",
+		"R": "ŔŖŘ", "r": "ŕŗř",
+		"S": "ŚŜŞŠ", "s": "śŝşš",
+		"T": "ŢŤ", "t": "ţť",
+		"U": "ÙÚÛÜŨŪŬŮŰŲ", "u": "ùúûüũūŭůűų",
+		"Y": "ÝŶŸ", "y": "ýÿŷ",
+		"Z": "ŹŻŽ", "z": "źżž",
+		"AE": "Æ", "ae": "æ", "OE": "Œ", "oe": "œ", "TH": "Þ", "th": "þ", "ss": "ß",
+		"'": "‘’‚′", "\"": "“”„″", "-": "‐‑‒–—", "...": "…", " ": "\u00a0",
+	} {
+		for _, r := range chars {
+			m[r] = ascii
+		}
+	}
+	return m
+}()
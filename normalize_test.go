@@ -0,0 +1,65 @@
+package cnab
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizer(t *testing.T) {
+	tests := []struct {
+		n    *Normalizer
+		in   string
+		want string
+	}{
+		{NewNormalizer(), "João Conceição Müller", "JOAO CONCEICAO MULLER"},
+		{NewNormalizer(), "Straße ⚡ Ação", "STRASSE  ACAO"},
+		{NewNormalizer(), "D’Ávila – Œuvre", "D'AVILA - OEUVRE"},
+		{&Normalizer{Transliterate: true}, "Pão", "Pao"},
+		{&Normalizer{Upper: true, Allowed: "ABCDEFGHIJKLMNOPQRSTUVWXYZ ", Replacement: '?'}, "rua 7, nº 10", "RUA ?? N? ??"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.n.Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+type normalizeRecord struct {
+	Name   string  `cnab:"size:8"`
+	City   *string `cnab:"size:6"`
+	Code   string  `cnab:"size:3;normalize:-"`
+	Forced string  `cnab:"size:3;normalize"`
+}
+
+func TestEncoderNormalizer(t *testing.T) {
+	city := "Sé"
+	in := normalizeRecord{Name: "Gonçalo", City: &city, Code: "ab", Forced: "é"}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != "Gonçalo "+"Sé    "+"ab "+"E  " {
+		t.Fatalf("unexpected line without normalizer: %q", data)
+	}
+
+	enc := NewEncoder()
+	enc.SetNormalizer(NewNormalizer())
+	data, err = enc.Encode(in)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if string(data) != "GONCALO "+"SE    "+"ab "+"E  " {
+		t.Fatalf("unexpected line: %q", data)
+	}
+}
+
+func TestNormalizeInvalidTag(t *testing.T) {
+	type Number struct {
+		N int `cnab:"size:2;normalize"`
+	}
+	if _, err := Marshal(Number{}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+}
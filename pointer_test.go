@@ -0,0 +1,87 @@
+package cnab
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type pointerRecord struct {
+	Code     *int       `cnab:"size:3;fill:0;align:right"`
+	Name     *string    `cnab:"size:5"`
+	Discount *time.Time `cnab:"size:8;blank:0"`
+	Amount   *Decimal   `cnab:"size:6;fill:0;align:right;decimal:2"`
+}
+
+func TestPointerNil(t *testing.T) {
+	data, err := Marshal(pointerRecord{})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != "000     00000000000000" {
+		t.Fatalf("unexpected line: '%s'", data)
+	}
+
+	out := pointerRecord{Code: new(int)}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.Code != nil || out.Name != nil || out.Discount != nil || out.Amount != nil {
+		t.Fatalf("expected nil fields, got %+v", out)
+	}
+}
+
+func TestPointerValues(t *testing.T) {
+	code, name := 7, "ACME"
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	amount := NewDecimal(1050, 2)
+	in := pointerRecord{Code: &code, Name: &name, Discount: &date, Amount: &amount}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != "007ACME 20240301001050" {
+		t.Fatalf("unexpected line: '%s'", data)
+	}
+
+	var out pointerRecord
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if *out.Code != 7 || *out.Name != "ACME" || !out.Discount.Equal(date) || out.Amount.Cmp(amount) != 0 {
+		t.Fatalf("unexpected values: %+v", out)
+	}
+
+	// A zero written with a space fill stays distinct from nil
+	type Spaced struct {
+		Count *int `cnab:"size:3;align:right"`
+	}
+	zero := 0
+	data, _ = Marshal(Spaced{Count: &zero})
+	var spaced Spaced
+	if err := Unmarshal(data, &spaced); err != nil || spaced.Count == nil || *spaced.Count != 0 {
+		t.Fatalf("expected zero count from '%s', got %v, %v", data, spaced.Count, err)
+	}
+}
+
+func TestPointerRequired(t *testing.T) {
+	type Required struct {
+		Due *time.Time `cnab:"size:8;blank:0;required"`
+	}
+
+	if _, err := Marshal(Required{}); !errors.Is(err, ErrRequired) {
+		t.Fatalf("expected ErrRequired on encode, got %v", err)
+	}
+	var out Required
+	if err := Unmarshal([]byte("00000000"), &out); !errors.Is(err, ErrRequired) {
+		t.Fatalf("expected ErrRequired on decode, got %v", err)
+	}
+
+	type BadBlank struct {
+		Due *time.Time `cnab:"size:8;blank:000"`
+	}
+	if _, err := Marshal(BadBlank{}); !errors.Is(err, ErrInvalidTag) {
+		t.Fatalf("expected ErrInvalidTag, got %v", err)
+	}
+}
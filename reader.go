@@ -0,0 +1,215 @@
+package cnab
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Discriminator extracts the key used to select the layout of a line.
+type Discriminator func(line []byte) string
+
+// Position returns a Discriminator that keys records by the characters
+// between the 1-based start and end positions (inclusive).
+// Lines too short to contain the interval yield an empty key.
+func Position(start, end int) Discriminator {
+	return func(line []byte) string {
+		text := newChars(string(line))
+		if start < 1 || start > end || end > text.len() {
+			return ""
+		}
+		return text.slice(start, end)
+	}
+}
+
+// Record is a decoded line yielded by a Reader.
+type Record struct {
+	Line  int         // 1-based line number in the input
+	Key   string      // discriminator value that selected the layout
+	Value interface{} // pointer to the decoded struct
+}
+
+// Reader reads multi-record CNAB files line by line, decoding each line
+// into the struct type registered for its discriminator value.
+type Reader struct {
+	scanner *bufio.Scanner
+	disc    Discriminator
+	types   map[string]reflect.Type
+	line    int
+	collect bool
+	seq     numbering
+	charset *Charset
+	verify  *verifier // nil unless VerifyTotals was called
+	lotKeys [2]string // discriminator keys of lot headers and trailers
+}
+
+// NewReader creates a Reader that reads lines from r.
+// Both LF and CRLF line terminators are accepted.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		scanner: bufio.NewScanner(r),
+		types:   make(map[string]reflect.Type),
+	}
+}
+
+// SetDiscriminator sets the function used to key each line.
+// Without a discriminator every line has the empty key.
+func (r *Reader) SetDiscriminator(d Discriminator) {
+	r.disc = d
+}
+
+// CollectErrors makes the Reader continue past failing lines and fields.
+// Read returns partially decoded records together with their errors, and
+// ReadAll and ReadFile240 return every failure as a single ErrorList.
+func (r *Reader) CollectErrors() {
+	r.collect = true
+}
+
+// SetCharset sets the charset of the input. Lines are converted to UTF-8
+// before the discriminator and the decoder see them. Without a charset,
+// input is taken as UTF-8 as is.
+func (r *Reader) SetCharset(cs *Charset) {
+	r.charset = cs
+}
+
+// VerifyTotals makes the Reader check the `count` and `sum` fields of the
+// records it reads, typically trailers, against the records before them.
+// Mismatches are reported as a FieldError wrapping an *IntegrityError.
+// Without SetLotKeys every total is taken over the whole file.
+func (r *Reader) VerifyTotals() {
+	r.verify = newVerifier()
+}
+
+// SetLotKeys declares the discriminator keys of lot headers and lot
+// trailers, so that lot trailers are verified against their lot only and
+// seq:lot numbers restart at each lot. ReadFile240 sets them.
+func (r *Reader) SetLotKeys(header, trailer string) {
+	r.lotKeys = [2]string{header, trailer}
+}
+
+// Register associates the struct type of v with a discriminator key.
+// v may be a struct value or a pointer to one; decoded records hold
+// a pointer to a new value of that type.
+func (r *Reader) Register(key string, v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrInvalidStruct
+	}
+	r.types[key] = t
+	return nil
+}
+
+// Read decodes the next record. It returns io.EOF when the input is exhausted.
+// Empty lines and a trailing EOF marker (Ctrl-Z) are skipped.
+func (r *Reader) Read() (*Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if isBlankLine(line) {
+			continue
+		}
+
+		text := string(line)
+		if r.charset != nil {
+			var err error
+			if text, err = r.charset.Decode(line); err != nil {
+				r.skip()
+				return nil, fmt.Errorf("line %d: %w", r.line, err)
+			}
+			line = []byte(text)
+		}
+
+		key := ""
+		if r.disc != nil {
+			key = r.disc(line)
+		}
+
+		t, ok := r.types[key]
+		if !ok {
+			r.skip()
+			return nil, fmt.Errorf("line %d: %w %q", r.line, ErrUnknownRecord, key)
+		}
+
+		chars := newChars(text)
+		rec := &Record{Line: r.line, Key: key, Value: reflect.New(t).Interface()}
+		err := decode(chars, rec.Value, r.collect)
+		if c, cerr := codecFor(t); cerr == nil {
+			// Record numbers are verified even when other fields fail
+			if serr := r.seq.check(c, chars); serr != nil {
+				err = joinErrors(err, serr)
+			}
+			r.seq.next()
+
+			openLot := r.lotKeys[0] != "" && key == r.lotKeys[0]
+			closeLot := r.lotKeys[1] != "" && key == r.lotKeys[1]
+			if openLot || closeLot {
+				r.seq.restartLot()
+			}
+			if r.verify != nil {
+				if verr := r.verify.record(c, t, chars, openLot, closeLot); verr != nil {
+					err = joinErrors(err, verr)
+				}
+			}
+		}
+		if err != nil {
+			if r.collect {
+				return rec, withLine(err, r.line)
+			}
+			return nil, withLine(err, r.line)
+		}
+
+		return rec, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// skip counts a line that could not be decoded, keeping record numbers
+// and totals in step.
+func (r *Reader) skip() {
+	r.seq.next()
+	if r.verify != nil {
+		r.verify.record(nil, nil, chars{}, false, false)
+	}
+}
+
+// ReadAll reads all remaining records.
+func (r *Reader) ReadAll() ([]*Record, error) {
+	var records []*Record
+	var errs ErrorList
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records, errs.err()
+		}
+		if err != nil {
+			if !r.canContinue(rec, err) {
+				return records, err
+			}
+			errs.add(err)
+		}
+		if rec != nil {
+			records = append(records, rec)
+		}
+	}
+}
+
+// canContinue reports whether reading may go on after err in collect mode.
+// Decoding failures, unknown records and lines invalid in the charset are
+// recoverable; I/O errors are not.
+func (r *Reader) canContinue(rec *Record, err error) bool {
+	return r.collect && (rec != nil || errors.Is(err, ErrUnknownRecord) || errors.Is(err, ErrCharset))
+}
+
+func isBlankLine(line []byte) bool {
+	return len(bytes.Trim(line, "\x1a")) == 0
+}
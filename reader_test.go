@@ -0,0 +1,81 @@
+package cnab
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type readerHeader struct {
+	Type string `cnab:"size:1"`
+	Name string `cnab:"size:10"`
+}
+
+type readerDetail struct {
+	Type   string `cnab:"size:1"`
+	Amount int    `cnab:"size:5;fill:0;align:right"`
+}
+
+func newTestReader(input string) *Reader {
+	r := NewReader(strings.NewReader(input))
+	r.SetDiscriminator(Position(1, 1))
+	r.Register("0", readerHeader{})
+	r.Register("1", &readerDetail{})
+	return r
+}
+
+func TestReaderDispatch(t *testing.T) {
+	input := "0BANK      \r\n100010\r\n100025\r\n\x1a"
+	r := newTestReader(input)
+
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	h, ok := records[0].Value.(*readerHeader)
+	if !ok || h.Name != "BANK" {
+		t.Fatalf("unexpected header: %#v", records[0].Value)
+	}
+
+	d, ok := records[2].Value.(*readerDetail)
+	if !ok || d.Amount != 25 {
+		t.Fatalf("unexpected detail: %#v", records[2].Value)
+	}
+
+	if records[2].Line != 3 || records[2].Key != "1" {
+		t.Fatalf("unexpected record position: line %d key %q", records[2].Line, records[2].Key)
+	}
+
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestReaderUnknownRecord(t *testing.T) {
+	r := newTestReader("0BANK      \n9XXXXX\n")
+
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	_, err := r.Read()
+	if !errors.Is(err, ErrUnknownRecord) {
+		t.Fatalf("expected ErrUnknownRecord, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected line number in error, got %v", err)
+	}
+}
+
+func TestReaderRegisterInvalid(t *testing.T) {
+	r := NewReader(strings.NewReader(""))
+	if err := r.Register("0", 10); !errors.Is(err, ErrInvalidStruct) {
+		t.Fatalf("expected ErrInvalidStruct, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package cnab
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type repeatSplit struct {
+	Conta int     `cnab:"size:4;fill:0;align:right"`
+	Valor Decimal `cnab:"size:6;fill:0;align:right;decimal:2"`
+}
+
+type repeatRecord struct {
+	Tipo      string        `cnab:"literal:R"`
+	Mensagens [2]string     `cnab:"size:5"`
+	Splits    []repeatSplit `cnab:"repeat:3;omitblank"`
+	Codigos   []int         `cnab:"size:2;fill:0;align:right;repeat:2"`
+}
+
+func TestRepeatRoundTrip(t *testing.T) {
+	in := repeatRecord{
+		Tipo:      "R",
+		Mensagens: [2]string{"OLA", "TCHAU"},
+		Splits:    []repeatSplit{{Conta: 12, Valor: NewDecimal(1050, 2)}, {Conta: 34, Valor: NewDecimal(100, 2)}},
+		Codigos:   []int{7},
+	}
+
+	data, err := Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := "R" + "OLA  TCHAU" + "0012001050" + "0034000100" + "0000000000" + "07" + "00"
+	if string(data) != want {
+		t.Fatalf("unexpected line:\nGot:  '%s'\nWant: '%s'", data, want)
+	}
+
+	var out repeatRecord
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	// Trailing blank splits are dropped; codes keep every occurrence
+	in.Codigos = []int{7, 0}
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("round trip mismatch:\nGot:  %+v\nWant: %+v", out, in)
+	}
+
+	specs, err := Describe(out)
+	if err != nil {
+		t.Fatalf("Describe failed: %v", err)
+	}
+	if s := specs[8]; s.Name != "Splits[2].Valor" || s.Start != 36 || s.End != 41 {
+		t.Fatalf("unexpected spec: %+v", s)
+	}
+}
+
+func TestRepeatTooMany(t *testing.T) {
+	in := repeatRecord{Splits: make([]repeatSplit, 4)}
+
+	_, err := Marshal(in)
+	var fe *FieldError
+	if !errors.As(err, &fe) || !errors.Is(err, ErrTooManyOccurrences) || fe.Field != "Splits" {
+		t.Fatalf("expected ErrTooManyOccurrences on Splits, got %v", err)
+	}
+}
+
+func TestRepeatInvalidTag(t *testing.T) {
+	type ArrayCount struct {
+		Codes [2]int `cnab:"size:2;repeat:3"`
+	}
+	type ArrayOmit struct {
+		Codes [2]int `cnab:"size:2;omitblank"`
+	}
+	type Scalar struct {
+		Code int `cnab:"size:2;repeat:3"`
+	}
+	type NegativeSize struct {
+		M []string `cnab:"size:-1;repeat:2"`
+	}
+	type NoSize struct {
+		M [2]string `cnab:"start:1"`
+	}
+
+	for _, v := range []interface{}{ArrayCount{}, ArrayOmit{}, Scalar{}, NegativeSize{}, NoSize{}} {
+		if _, err := Marshal(v); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%T: expected ErrInvalidTag, got %v", v, err)
+		}
+		if err := ValidateLayout(v, ValidateOptions{}); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%T: ValidateLayout: expected ErrInvalidTag, got %v", v, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package cnab
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type requiredStruct struct {
+	Code int       `cnab:"size:3;fill:0;align:right;required"`
+	Name string    `cnab:"size:5;required"`
+	Date time.Time `cnab:"size:8;format:20060102;required"`
+	Note string    `cnab:"size:3"`
+}
+
+func TestRequiredEncode(t *testing.T) {
+	s := requiredStruct{
+		Code: 1,
+		Date: time.Date(2023, 10, 25, 0, 0, 0, 0, time.UTC),
+	}
+
+	_, err := Marshal(s)
+	if !errors.Is(err, ErrRequired) {
+		t.Fatalf("expected ErrRequired, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Name") {
+		t.Fatalf("expected field name in error, got %v", err)
+	}
+
+	s.Name = "JOHN"
+	data, err := Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != "001JOHN 20231025   " {
+		t.Fatalf("unexpected output: '%s'", string(data))
+	}
+}
+
+func TestRequiredDecode(t *testing.T) {
+	var s requiredStruct
+	if err := Unmarshal([]byte("001JOHN 20231025   "), &s); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	err := Unmarshal([]byte("000JOHN 20231025   "), &s)
+	if !errors.Is(err, ErrRequired) || !strings.Contains(err.Error(), "Code") {
+		t.Fatalf("expected ErrRequired for Code, got %v", err)
+	}
+
+	err = Unmarshal([]byte("001JOHN            "), &s)
+	if !errors.Is(err, ErrRequired) || !strings.Contains(err.Error(), "Date") {
+		t.Fatalf("expected ErrRequired for Date, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package cnab
+
+import "reflect"
+
+// Scopes of the `seq` tag.
+const (
+	SeqFile = "file" // record number within the file
+	SeqLot  = "lot"  // record number within the lot
+)
+
+// numbering tracks the record numbers of a file being written or read.
+// A lot sequence counts the records after the lot header and restarts at
+// each lot header and trailer.
+type numbering struct {
+	records   int // records so far in the file
+	lot       int // records so far since the last lot header or trailer
+	fileShift int // adjustments after gaps, so each gap is reported once
+	lotShift  int
+}
+
+// expected returns the number the seq field f must hold in the next record.
+func (n *numbering) expected(f *codecField) int {
+	if f.tag.seq == SeqLot {
+		return f.tag.seqStart + n.lot + n.lotShift
+	}
+	return f.tag.seqStart + n.records + n.fileShift
+}
+
+// fill sets the seq fields of rv, the next record to be written.
+func (n *numbering) fill(c *codec, rv reflect.Value) error {
+	for _, f := range c.seqs {
+		if err := setNumber(f.target(rv), NewDecimal(int64(n.expected(f)), 0), &f.tag); err != nil {
+			return f.fieldError(err, "")
+		}
+	}
+	return nil
+}
+
+// check verifies the seq fields of line, a record of codec c, and resumes
+// each broken sequence from the number found.
+func (n *numbering) check(c *codec, line chars) error {
+	var errs ErrorList
+	for _, f := range c.seqs {
+		if f.end > line.len() {
+			continue
+		}
+		want := n.expected(f)
+		got := 0
+		d, ok, err := f.number(line)
+		if err != nil {
+			errs.add(err)
+			continue
+		}
+		if ok {
+			got = int(d.Rescale(0, RoundDown).Unscaled().Int64())
+		}
+		if got == want {
+			continue
+		}
+
+		err = f.fieldError(&SequenceError{Expected: want, Actual: got}, line.slice(f.start, f.end))
+		errs.add(err)
+		if f.tag.seq == SeqLot {
+			n.lotShift += got - want
+		} else {
+			n.fileShift += got - want
+		}
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errs.err()
+}
+
+// next moves past a record.
+func (n *numbering) next() {
+	n.records++
+	n.lot++
+}
+
+// restartLot moves past a lot header or trailer.
+func (n *numbering) restartLot() {
+	n.lot, n.lotShift = 0, 0
+}
@@ -0,0 +1,109 @@
+package cnab
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type seqHeader struct {
+	Type string `cnab:"literal:0"`
+	Seq  int    `cnab:"size:3;fill:0;align:right;seq:file"`
+}
+
+type seqDetail struct {
+	Type   string `cnab:"literal:1"`
+	Amount int    `cnab:"size:2;fill:0;align:right"`
+	Lot    int    `cnab:"size:2;fill:0;align:right;seq:lot"`
+	Seq    int    `cnab:"size:3;fill:0;align:right;seq:file"`
+}
+
+// seqNote is a lot record without a lot sequence of its own.
+type seqNote struct {
+	Type string `cnab:"literal:2"`
+	Seq  int    `cnab:"size:3;fill:0;align:right;seq:file"`
+}
+
+func TestWriterSequence(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetLineEnding(LF)
+
+	steps := []func() error{
+		func() error { return w.BeginLot(seqHeader{}) },
+		func() error { return w.Write(seqDetail{Amount: 1}) },
+		func() error { return w.Write(seqNote{}) },
+		func() error { return w.Write(&seqDetail{Amount: 2}) },
+		func() error { return w.EndLot(seqHeader{}) },
+		func() error { return w.BeginLot(seqHeader{}) },
+		func() error { return w.Write(seqDetail{Amount: 3}) },
+		func() error { return w.EndLot(seqHeader{}) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	// The note is numbered within the lot without restarting it
+	want := "0001\n10101002\n2003\n10203004\n0005\n0006\n10301007\n0008\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected file:\nGot:  %q\nWant: %q", buf.String(), want)
+	}
+}
+
+func TestReaderSequence(t *testing.T) {
+	newReader := func(data string) *Reader {
+		r := NewReader(strings.NewReader(data))
+		r.SetDiscriminator(Position(1, 1))
+		r.Register("0", seqHeader{})
+		r.Register("1", seqDetail{})
+		r.Register("2", seqNote{})
+		r.SetLotKeys("0", "")
+		return r
+	}
+
+	if _, err := newReader("0001\n10101002\n2003\n10203004\n").ReadAll(); err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+
+	// Record 3 is missing: a single gap is reported, then counting resumes
+	r := newReader("0001\n10101002\n10202004\n10303005\n")
+	r.CollectErrors()
+	records, err := r.ReadAll()
+	if len(records) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(records))
+	}
+
+	var list ErrorList
+	var se *SequenceError
+	var fe *FieldError
+	if !errors.As(err, &list) || len(list) != 1 || !errors.As(err, &se) || !errors.As(err, &fe) {
+		t.Fatalf("expected a single sequence error, got %v", err)
+	}
+	if se.Expected != 3 || se.Actual != 4 || fe.Field != "Seq" || fe.Line != 3 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(err, ErrSequence) {
+		t.Fatalf("expected ErrSequence, got %v", err)
+	}
+}
+
+func TestSequenceInvalidTag(t *testing.T) {
+	type Scope struct {
+		N int `cnab:"size:2;seq:page"`
+	}
+	type Text struct {
+		S string `cnab:"size:2;seq:file"`
+	}
+
+	for _, v := range []interface{}{Scope{}, Text{}} {
+		if _, err := Marshal(v); !errors.Is(err, ErrInvalidTag) {
+			t.Errorf("%T: expected ErrInvalidTag, got %v", v, err)
+		}
+	}
+}
@@ -18,6 +18,37 @@ type fieldTag struct {
 	decimal      int
 	hasDate      bool
 	literalValue string
+	required     bool
+	rounding     RoundingMode
+	trueCode     string // empty means "S"
+	falseCode    string // empty means "N"
+	enumSpec     string // enum tag value
+	enum         *CodeSet
+	blank        string // text of nil pointers: one character repeated or the whole field
+	repeat       int    // occurrences of a repeated field
+	omitBlank    bool   // drop trailing zero occurrences of a repeated slice on decode
+	count        string // aggregate: "records" or "lots"
+	sum          string // aggregate: "Type.Field" or "Field"
+	seq          string // record numbering scope: "file" or "lot"
+	seqStart     int    // number of the first record of the scope
+	normalize    string // "on" forces the default Normalizer, "-" disables normalization
+}
+
+// aggregate reports whether the field is computed by the Writer.
+func (ft *fieldTag) aggregate() bool {
+	return ft.count != "" || ft.sum != ""
+}
+
+// boolCodes returns the codes written for true and false.
+func (ft *fieldTag) boolCodes() (string, string) {
+	t, f := ft.trueCode, ft.falseCode
+	if t == "" {
+		t = "S"
+	}
+	if f == "" {
+		f = "N"
+	}
+	return t, f
 }
 
 func parseTag(tag string) (fieldTag, error) {
@@ -86,12 +117,80 @@ func parseTag(tag string) (fieldTag, error) {
 			// ignore, just the tag name itself if passed incorrectly
 		case "literal":
 			ft.literalValue = value
+		case "required":
+			ft.required = true
+		case "true":
+			ft.trueCode = value
+		case "false":
+			ft.falseCode = value
+		case "blank":
+			ft.blank = value
+		case "enum":
+			set, err := ParseCodeSet(value)
+			if err != nil {
+				return ft, err
+			}
+			ft.enumSpec, ft.enum = value, set
+		case "repeat":
+			v, err := strconv.Atoi(value)
+			if err != nil || v < 1 {
+				return ft, ErrInvalidTag
+			}
+			ft.repeat = v
+		case "omitblank":
+			ft.omitBlank = true
+		case "count":
+			if value != CountRecords && value != CountLots {
+				return ft, errors.Wrap(ErrInvalidTag, fmt.Sprintf("unknown count %q", value))
+			}
+			ft.count = value
+		case "normalize":
+			switch value {
+			case "", "on":
+				ft.normalize = "on"
+			case "-":
+				ft.normalize = "-"
+			default:
+				return ft, errors.Wrap(ErrInvalidTag, fmt.Sprintf("invalid normalize %q", value))
+			}
+		case "seq":
+			scope, start, hasStart := strings.Cut(value, ",")
+			ft.seq, ft.seqStart = strings.TrimSpace(scope), 1
+			if ft.seq != SeqFile && ft.seq != SeqLot {
+				return ft, errors.Wrap(ErrInvalidTag, fmt.Sprintf("unknown seq scope %q", ft.seq))
+			}
+			if hasStart {
+				v, err := strconv.Atoi(strings.TrimSpace(start))
+				if err != nil || v < 0 {
+					return ft, errors.Wrap(ErrInvalidTag, fmt.Sprintf("invalid seq start %q", start))
+				}
+				ft.seqStart = v
+			}
+		case "sum":
+			if value == "" {
+				return ft, errors.Wrap(ErrInvalidTag, "sum needs a field")
+			}
+			if strings.ContainsAny(value, " \t") || strings.HasPrefix(value, ".") ||
+				strings.HasSuffix(value, ".") || strings.Contains(value, "..") {
+				return ft, errors.Wrap(ErrInvalidTag, fmt.Sprintf("invalid sum field %q", value))
+			}
+			ft.sum = value
+		case "round":
+			mode, err := ParseRoundingMode(value)
+			if err != nil {
+				return ft, err
+			}
+			ft.rounding = mode
 		default:
 			// ignore unknown keys or handle as error?
 			// For now, ignore to be flexible
 		}
 	}
-	
+
+	if t, f := ft.boolCodes(); t == f {
+		return ft, errors.Wrap(ErrInvalidTag, fmt.Sprintf("true and false codes are both %q", t))
+	}
+
 	if ft.end != 0 {
 		if ft.start == 0 {
 			return ft, errors.Wrap(ErrInvalidTag, "start is mandatory when end is set")
@@ -100,12 +199,12 @@ func parseTag(tag string) (fieldTag, error) {
 		if ft.start > ft.end {
 			return ft, errors.Wrap(ErrInvalidTag, fmt.Sprintf("start %d must be less than end %d", ft.start, ft.end))
 		}
-		
+
 		// if size is set, it must match the interval
 		if ft.size != 0 && ft.size != ft.end-ft.start+1 {
 			return ft, errors.Wrap(ErrInvalidTag, fmt.Sprintf("size %d does not match interval %d-%d", ft.size, ft.start, ft.end))
 		}
-		
+
 		ft.size = ft.end - ft.start + 1
 	} else {
 		// auto size
@@ -113,13 +212,16 @@ func parseTag(tag string) (fieldTag, error) {
 			if ft.start == 0 {
 				// for literal, size is the length of the literal
 				if ft.literalValue != "" {
-					ft.size = len(ft.literalValue)
+					ft.size = runeCount(ft.literalValue)
 				} else if ft.format != "" {
-					ft.size = len(ft.format)
+					ft.size = runeCount(ft.format)
+				} else if ft.trueCode != "" || ft.falseCode != "" {
+					t, f := ft.boolCodes()
+					ft.size = max(runeCount(t), runeCount(f))
 				}
 			} else {
 				// in this case has start and size
-				ft.end = ft.start + len(ft.literalValue) - 1
+				ft.end = ft.start + runeCount(ft.literalValue) - 1
 				ft.size = ft.end - ft.start + 1
 			}
 
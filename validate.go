@@ -0,0 +1,72 @@
+package cnab
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ValidateOptions configures ValidateLayout.
+type ValidateOptions struct {
+	// RecordLength is the declared record width (e.g. CNAB240).
+	// Zero skips the range check and validates gaps up to the last field.
+	RecordLength int
+
+	// AllowGaps disables the report of positions not covered by any field.
+	AllowGaps bool
+}
+
+// ValidateLayout checks the tagged struct layout of v, which may be a struct
+// value or a pointer to one. It reports every problem found as an ErrorList:
+// invalid tags, unsupported field types, literals longer than their field,
+// overlapping intervals, uncovered gaps and fields beyond the record length.
+// It returns nil when the layout is valid.
+func ValidateLayout(v interface{}, opts ValidateOptions) error {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrInvalidStruct
+	}
+
+	c, errs := build(t)
+
+	for _, f := range c.fields {
+		if runeCount(f.tag.literalValue) > f.tag.size {
+			errs = append(errs, f.fieldError(fmt.Errorf("%w: literal %q longer than size %d",
+				ErrFieldSizeMismatch, f.tag.literalValue, f.tag.size), ""))
+		}
+		if opts.RecordLength > 0 && f.end > opts.RecordLength {
+			errs = append(errs, f.fieldError(fmt.Errorf("%w %d", ErrFieldOutOfRange, opts.RecordLength), ""))
+		}
+	}
+
+	// Sweep the fields by start position, comparing each one with the
+	// field that reaches furthest among the previous ones.
+	fields := make([]*codecField, len(c.fields))
+	copy(fields, c.fields)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].start < fields[j].start })
+
+	covered := 0
+	var furthest *codecField
+	for _, f := range fields {
+		if furthest != nil && f.start <= furthest.end {
+			errs = append(errs, f.fieldError(fmt.Errorf("%w with field %s at %d-%d",
+				ErrFieldOverlap, furthest.name, furthest.start, furthest.end), ""))
+		}
+		if !opts.AllowGaps && f.start > covered+1 {
+			errs = append(errs, fmt.Errorf("%w: %d-%d", ErrLayoutGap, covered+1, f.start-1))
+		}
+		if f.end > covered {
+			covered = f.end
+			furthest = f
+		}
+	}
+
+	if !opts.AllowGaps && opts.RecordLength > covered {
+		errs = append(errs, fmt.Errorf("%w: %d-%d", ErrLayoutGap, covered+1, opts.RecordLength))
+	}
+
+	return errs.err()
+}
@@ -0,0 +1,70 @@
+package cnab
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateLayoutValid(t *testing.T) {
+	for _, v := range []interface{}{header240{}, &segmentP240{}, trailer240{}} {
+		if err := ValidateLayout(v, ValidateOptions{RecordLength: 20}); err != nil {
+			t.Errorf("expected %T to be valid, got %v", v, err)
+		}
+	}
+}
+
+func TestValidateLayoutProblems(t *testing.T) {
+	type Broken struct {
+		A     string `cnab:"start:1;end:5"`
+		B     string `cnab:"start:4;size:3"`  // overlaps A
+		C     string `cnab:"start:10;size:2"` // gap 7-9
+		Lit   string `cnab:"size:2;literal:TOOLONG"`
+		Flags []int  `cnab:"size:2"`
+		Tail  string `cnab:"size:6"` // 16-21, beyond 20
+	}
+
+	err := ValidateLayout(Broken{}, ValidateOptions{RecordLength: 20})
+
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %T: %v", err, err)
+	}
+
+	for _, target := range []error{
+		ErrFieldOverlap,
+		ErrLayoutGap,
+		ErrFieldSizeMismatch,
+		ErrUnsupportedType,
+		ErrFieldOutOfRange,
+	} {
+		if !errors.Is(err, target) {
+			t.Errorf("expected %v to be reported, got:\n%v", target, err)
+		}
+	}
+
+	var fe *FieldError
+	for _, e := range list {
+		if errors.Is(e, ErrFieldOverlap) && errors.As(e, &fe) && fe.Field != "B" {
+			t.Errorf("expected overlap on field B, got %s", fe.Field)
+		}
+	}
+}
+
+func TestValidateLayoutGaps(t *testing.T) {
+	type Short struct {
+		A string `cnab:"size:5"`
+	}
+
+	err := ValidateLayout(Short{}, ValidateOptions{RecordLength: 8})
+	if !errors.Is(err, ErrLayoutGap) {
+		t.Fatalf("expected trailing gap, got %v", err)
+	}
+
+	if err := ValidateLayout(Short{}, ValidateOptions{RecordLength: 8, AllowGaps: true}); err != nil {
+		t.Fatalf("expected no error with AllowGaps, got %v", err)
+	}
+
+	if err := ValidateLayout(10, ValidateOptions{}); !errors.Is(err, ErrInvalidStruct) {
+		t.Fatalf("expected ErrInvalidStruct, got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package cnab
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"unicode/utf8"
+)
+
+// Common CNAB record lengths.
+const (
+	CNAB150 = 150
+	CNAB240 = 240
+	CNAB400 = 400
+)
+
+// Line terminators accepted by Writer.SetLineEnding.
+const (
+	CRLF = "\r\n"
+	LF   = "\n"
+)
+
+// CtrlZ is the end-of-file marker some banks expect after the last record.
+const CtrlZ = "\x1a"
+
+// Writer writes CNAB files record by record, terminating every
+// record (including the last one) with the configured line ending.
+// It counts the records and sums the numeric fields it writes, filling
+// the `count` and `sum` fields of trailers.
+type Writer struct {
+	w            *bufio.Writer
+	enc          *Encoder
+	lineEnding   string
+	eofMarker    string
+	recordLength int
+	file         *tally
+	lot          *tally // nil outside BeginLot/EndLot
+	seq          numbering
+	charset      *Charset
+}
+
+// NewWriter creates a Writer that writes to w using CRLF line endings.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		w:          bufio.NewWriter(w),
+		enc:        NewEncoder(),
+		lineEnding: CRLF,
+		file:       newTally(),
+	}
+}
+
+// SetLineEnding sets the terminator written after each record (CRLF or LF).
+func (w *Writer) SetLineEnding(s string) {
+	w.lineEnding = s
+}
+
+// SetEOFMarker sets the marker written by Close after the last record.
+// An empty marker (the default) writes nothing.
+func (w *Writer) SetEOFMarker(s string) {
+	w.eofMarker = s
+}
+
+// SetCharset sets the charset of the output, e.g. ISO88591. Records are
+// encoded as UTF-8 and converted when written; without a charset they are
+// written as UTF-8. The record length is checked in characters.
+func (w *Writer) SetCharset(cs *Charset) {
+	w.charset = cs
+}
+
+// SetNormalizer sets the Normalizer applied to string fields, as
+// Encoder.SetNormalizer does.
+func (w *Writer) SetNormalizer(n *Normalizer) {
+	w.enc.SetNormalizer(n)
+}
+
+// SetRecordLength sets the width every record must have (e.g. CNAB240).
+// Zero (the default) disables the check.
+func (w *Writer) SetRecordLength(n int) {
+	w.recordLength = n
+}
+
+// Write encodes v and writes it as a single record. Aggregate fields of v
+// are filled from the open lot, or from the file outside lots, and seq
+// fields with the record number; when v is a pointer they are also set on
+// the value it points to. A sum naming a field that no record written so
+// far has fails with ErrUnknownSum.
+func (w *Writer) Write(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return ErrInvalidStruct
+	}
+	c, err := codecFor(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	// Names are checked against the whole file, so empty lots may be summed
+	if err := w.file.checkSums(c); err != nil {
+		return err
+	}
+	if len(c.totals) > 0 || len(c.seqs) > 0 {
+		if !rv.CanAddr() {
+			addr := reflect.New(rv.Type()).Elem()
+			addr.Set(rv)
+			rv = addr
+		}
+		if err := w.scope().fill(c, rv); err != nil {
+			return err
+		}
+		if err := w.seq.fill(c, rv); err != nil {
+			return err
+		}
+		v = rv.Addr().Interface()
+	}
+
+	data, err := w.enc.Encode(v)
+	if err != nil {
+		return err
+	}
+	// Sum before writing, so that a failed Write writes nothing
+	s, err := c.summands(newChars(string(data)))
+	if err != nil {
+		return err
+	}
+	if err := w.writeLine(data); err != nil {
+		return err
+	}
+	w.seq.next()
+	w.count(rv.Type(), s)
+	return nil
+}
+
+// WriteLine writes an already encoded UTF-8 record. It is counted as a
+// record but its fields are not summed.
+func (w *Writer) WriteLine(data []byte) error {
+	if err := w.writeLine(data); err != nil {
+		return err
+	}
+	w.seq.next()
+	w.file.records++
+	if w.lot != nil {
+		w.lot.records++
+	}
+	return nil
+}
+
+// BeginLot opens a lot and writes its header. Records written until
+// EndLot are counted and summed for the lot trailer as well.
+func (w *Writer) BeginLot(header interface{}) error {
+	if w.lot != nil {
+		return fmt.Errorf("%w: lot header inside an open lot", ErrFileStructure)
+	}
+	w.lot = newTally()
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	w.seq.restartLot()
+	return nil
+}
+
+// EndLot writes the lot trailer, with its aggregates computed over the
+// lot, and closes the lot.
+func (w *Writer) EndLot(trailer interface{}) error {
+	if w.lot == nil {
+		return fmt.Errorf("%w: lot trailer without lot header", ErrFileStructure)
+	}
+	if err := w.Write(trailer); err != nil {
+		return err
+	}
+	w.lot = nil
+	w.file.lots++
+	w.seq.restartLot()
+	return nil
+}
+
+// scope returns the tally that aggregates of the next record are taken from.
+func (w *Writer) scope() *tally {
+	if w.lot != nil {
+		return w.lot
+	}
+	return w.file
+}
+
+// count adds a written record to the open tallies.
+func (w *Writer) count(t reflect.Type, s []summand) {
+	w.file.add(t, s)
+	if w.lot != nil {
+		w.lot.add(t, s)
+	}
+}
+
+// writeLine writes data, a UTF-8 record, in the Writer's charset and the
+// line ending.
+func (w *Writer) writeLine(data []byte) error {
+	if n := utf8.RuneCount(data); w.recordLength > 0 && n != w.recordLength {
+		return fmt.Errorf("%w: got %d, want %d", ErrRecordLength, n, w.recordLength)
+	}
+	if w.charset != nil {
+		var err error
+		if data, err = w.charset.Encode(string(data)); err != nil {
+			return err
+		}
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.w.WriteString(w.lineEnding)
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Close writes the EOF marker, if any, and flushes the Writer.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.eofMarker != "" {
+		if _, err := w.w.WriteString(w.eofMarker); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,61 @@
+package cnab
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWriterLineEndings(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	if err := w.Write(readerHeader{Type: "0", Name: "BANK"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Write(readerDetail{Type: "1", Amount: 10}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	expected := "0BANK      \r\n100010\r\n"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestWriterLFAndEOFMarker(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetLineEnding(LF)
+	w.SetEOFMarker(CtrlZ)
+
+	if err := w.Write(readerDetail{Type: "1", Amount: 7}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	expected := "100007\n\x1a"
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestWriterRecordLength(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetRecordLength(11)
+
+	if err := w.Write(readerHeader{Type: "0", Name: "BANK"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	err := w.Write(readerDetail{Type: "1", Amount: 7})
+	if !errors.Is(err, ErrRecordLength) {
+		t.Fatalf("expected ErrRecordLength, got %v", err)
+	}
+}